@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	onelog "github.com/francoispqt/onelog"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// allocationBudgetsFile holds the maximum number of allocations made by
+	// a single invocation of each benchmark scenario.
+	allocationBudgetsFile = "test_data/allocation_budgets.json"
+	// allocationRuns is the number of invocations averaged when measuring
+	// the allocations of a scenario.
+	allocationRuns = 20
+
+	largePodContainers  = 50
+	largePodAnnotations = 100
+	annotationSize      = 1024
+	largeDenyListSize   = 5000
+)
+
+// benchmarkScenario is a single invocation of the policy, measured both by
+// the benchmarks and by TestAllocationBudgets.
+type benchmarkScenario struct {
+	// function is the waPC function invoked, either `validate` or
+	// `validate_settings`.
+	function string
+	payload  []byte
+}
+
+// invoke calls the function of the scenario through a new evaluator, like
+// the policy does for each invocation. The logs are encoded as usual and
+// then discarded, to keep the output of the benchmarks readable.
+func (s *benchmarkScenario) invoke() ([]byte, error) {
+	ev := newEvaluator()
+	ev.logger = onelog.New(io.Discard, onelog.ALL)
+
+	if s.function == "validate_settings" {
+		return ev.validateSettings(s.payload)
+	}
+
+	return ev.validate(s.payload)
+}
+
+// benchmarkPod creates a Pod with the given number of containers and of
+// annotations, each annotation holding annotationSize bytes.
+func benchmarkPod(containers, annotations int) *corev1.Pod {
+	pod := &corev1.Pod{
+		Metadata: &metav1.ObjectMeta{
+			Name:        "test-pod",
+			Namespace:   "default",
+			Annotations: map[string]string{},
+		},
+		Spec: &corev1.PodSpec{},
+	}
+	for i := range containers {
+		name := fmt.Sprintf("container-%d", i)
+		pod.Spec.Containers = append(pod.Spec.Containers, &corev1.Container{
+			Name:  &name,
+			Image: fmt.Sprintf("registry.example.com/app-%d:1.0", i),
+		})
+	}
+	for i := range annotations {
+		pod.Metadata.Annotations[fmt.Sprintf("example.com/annotation-%d", i)] = strings.Repeat("x", annotationSize)
+	}
+
+	return pod
+}
+
+// benchmarkDenyList creates a deny list of the given size, which doesn't
+// hold the name of the Pods used by the benchmarks.
+func benchmarkDenyList(size int) []DenyEntry {
+	entries := make([]DenyEntry, 0, size)
+	for i := range size {
+		entries = append(entries, DenyEntry{
+			Name:   fmt.Sprintf("denied-%d", i),
+			Reason: "reserved for the platform team",
+			Owner:  "team-x",
+		})
+	}
+
+	return entries
+}
+
+// benchmarkScenarios returns the scenarios measured by the benchmarks, keyed
+// by the name used inside of the allocation budgets file.
+func benchmarkScenarios(tb testing.TB) map[string]*benchmarkScenario {
+	tb.Helper()
+
+	smallSettings := &Settings{DeniedNames: denyEntries("foo", "bar", "baz")}
+	largeSettings := &Settings{DeniedNames: benchmarkDenyList(largeDenyListSize)}
+	deniedSettings := &Settings{DeniedNames: append(benchmarkDenyList(largeDenyListSize), DenyEntry{Name: "test-pod"})}
+	smallPod := benchmarkPod(1, 0)
+
+	return map[string]*benchmarkScenario{
+		"validate/small-pod": {
+			function: "validate",
+			payload:  newRequest(tb, smallPod).Settings(smallSettings).Payload(),
+		},
+		"validate/large-pod": {
+			function: "validate",
+			payload: newRequest(tb, benchmarkPod(largePodContainers, largePodAnnotations)).
+				Settings(smallSettings).Payload(),
+		},
+		"validate/large-deny-list": {
+			function: "validate",
+			payload:  newRequest(tb, smallPod).Settings(largeSettings).Payload(),
+		},
+		"validate/large-deny-list-rejection": {
+			function: "validate",
+			payload:  newRequest(tb, smallPod).Settings(deniedSettings).Payload(),
+		},
+		"validate_settings/small-deny-list": {
+			function: "validate_settings",
+			payload:  rawObject(tb, smallSettings),
+		},
+		"validate_settings/large-deny-list": {
+			function: "validate_settings",
+			payload:  rawObject(tb, largeSettings),
+		},
+	}
+}
+
+func runBenchmarkScenario(b *testing.B, name string) {
+	scenario, found := benchmarkScenarios(b)[name]
+	if !found {
+		b.Fatalf("Unknown scenario %s", name)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(scenario.payload)))
+	b.ResetTimer()
+	for range b.N {
+		if _, err := scenario.invoke(); err != nil {
+			b.Fatalf("Unexpected error: %+v", err)
+		}
+	}
+}
+
+func BenchmarkValidateSmallPod(b *testing.B) {
+	runBenchmarkScenario(b, "validate/small-pod")
+}
+
+func BenchmarkValidateLargePod(b *testing.B) {
+	runBenchmarkScenario(b, "validate/large-pod")
+}
+
+func BenchmarkValidateLargeDenyList(b *testing.B) {
+	runBenchmarkScenario(b, "validate/large-deny-list")
+}
+
+func BenchmarkValidateLargeDenyListRejection(b *testing.B) {
+	runBenchmarkScenario(b, "validate/large-deny-list-rejection")
+}
+
+func BenchmarkValidateSettingsSmallDenyList(b *testing.B) {
+	runBenchmarkScenario(b, "validate_settings/small-deny-list")
+}
+
+func BenchmarkValidateSettingsLargeDenyList(b *testing.B) {
+	runBenchmarkScenario(b, "validate_settings/large-deny-list")
+}
+
+// TestAllocationBudgets ensures no scenario of the benchmarks allocates more
+// than its budget, checked into allocationBudgetsFile. Raising a budget is a
+// deliberate change, visible during review.
+func TestAllocationBudgets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measuring the allocations is slow")
+	}
+	if raceEnabled {
+		t.Skip("the race detector changes the allocations")
+	}
+
+	data, err := os.ReadFile(allocationBudgetsFile)
+	if err != nil {
+		t.Fatalf("Cannot read the allocation budgets: %+v", err)
+	}
+	budgets := map[string]float64{}
+	if err = json.Unmarshal(data, &budgets); err != nil {
+		t.Fatalf("Cannot decode the allocation budgets: %+v", err)
+	}
+
+	for name, scenario := range benchmarkScenarios(t) {
+		t.Run(name, func(t *testing.T) {
+			budget, found := budgets[name]
+			if !found {
+				t.Fatalf("%s has no budget inside of %s", name, allocationBudgetsFile)
+			}
+
+			allocations := testing.AllocsPerRun(allocationRuns, func() {
+				if _, err := scenario.invoke(); err != nil {
+					t.Fatalf("Unexpected error: %+v", err)
+				}
+			})
+			if allocations > budget {
+				t.Errorf("%.0f allocations per invocation, over the budget of %.0f", allocations, budget)
+			}
+		})
+	}
+}
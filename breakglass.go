@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBreakGlassAnnotation = "policy.example.com/break-glass"
+	minBreakGlassKeyLength      = 32
+	breakGlassTokenParts        = 2
+)
+
+// BreakGlassSettings configures the emergency bypass of the deny rules.
+//
+// Objects can carry an annotation holding a token made of two base64url
+// encoded parts, separated by a dot: the JSON claims and their HMAC-SHA256
+// signature. The signature is computed over the encoded claims, using one of
+// the configured keys.
+type BreakGlassSettings struct {
+	// Annotation holding the token. Defaults to
+	// "policy.example.com/break-glass".
+	Annotation string          `json:"annotation,omitempty"`
+	Keys       []BreakGlassKey `json:"keys"`
+}
+
+// BreakGlassKey is a key that can be used to sign break-glass tokens.
+type BreakGlassKey struct {
+	ID string `json:"id"`
+	// Secret is the base64 encoded key, which must be at least 32 bytes long.
+	Secret string `json:"secret"`
+}
+
+// BreakGlassClaims is the content of a break-glass token.
+type BreakGlassClaims struct {
+	// KeyID is the ID of the key used to sign the token.
+	KeyID     string    `json:"kid"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Namespace and Name optionally restrict the token to a single object.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// AnnotationName returns the name of the annotation holding the token.
+func (b *BreakGlassSettings) AnnotationName() string {
+	if b.Annotation == "" {
+		return defaultBreakGlassAnnotation
+	}
+
+	return b.Annotation
+}
+
+// Validate ensures all the keys are usable.
+func (b *BreakGlassSettings) Validate() error {
+	if len(b.Keys) == 0 {
+		return errors.New("break_glass: at least one key must be provided")
+	}
+
+	ids := map[string]bool{}
+	for i, key := range b.Keys {
+		if key.ID == "" {
+			return fmt.Errorf("break_glass.keys[%d]: id cannot be empty", i)
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("break_glass.keys[%d]: duplicated id '%s'", i, key.ID)
+		}
+		ids[key.ID] = true
+
+		secret, err := base64.StdEncoding.DecodeString(key.Secret)
+		if err != nil {
+			return fmt.Errorf("break_glass.keys[%d]: secret is not base64 encoded: %w", i, err)
+		}
+		if len(secret) < minBreakGlassKeyLength {
+			return fmt.Errorf("break_glass.keys[%d]: secret must be at least %d bytes long", i, minBreakGlassKeyLength)
+		}
+	}
+
+	return nil
+}
+
+// Verify checks the token found inside of the annotation of the given object
+// and returns its claims. The error explains why the token cannot be used.
+func (b *BreakGlassSettings) Verify(token, namespace, name string, now time.Time) (*BreakGlassClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != breakGlassTokenParts {
+		return nil, errors.New("token must be made of the claims and the signature, separated by a dot")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("token signature is not base64url encoded")
+	}
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("token claims are not base64url encoded")
+	}
+	claims := BreakGlassClaims{}
+	if err = json.Unmarshal(rawClaims, &claims); err != nil {
+		return nil, fmt.Errorf("token claims cannot be decoded: %w", err)
+	}
+
+	key, err := b.findKey(claims.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(parts[0]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("token signature does not match key '%s'", claims.KeyID)
+	}
+
+	if claims.Reason == "" {
+		return nil, errors.New("token does not have a reason")
+	}
+	if claims.ExpiresAt.IsZero() {
+		return nil, errors.New("token does not have an expiration date")
+	}
+	if !now.Before(claims.ExpiresAt) {
+		return nil, fmt.Errorf("token expired at %s", claims.ExpiresAt.Format(time.RFC3339))
+	}
+	if claims.Namespace != "" && claims.Namespace != namespace {
+		return nil, fmt.Errorf("token is restricted to the '%s' namespace", claims.Namespace)
+	}
+	if claims.Name != "" && claims.Name != name {
+		return nil, fmt.Errorf("token is restricted to objects named '%s'", claims.Name)
+	}
+
+	return &claims, nil
+}
+
+func (b *BreakGlassSettings) findKey(id string) ([]byte, error) {
+	for _, key := range b.Keys {
+		if key.ID == id {
+			return base64.StdEncoding.DecodeString(key.Secret)
+		}
+	}
+
+	return nil, fmt.Errorf("token is signed with unknown key '%s'", id)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+const testBreakGlassSecret = "0123456789abcdef0123456789abcdef"
+
+func testBreakGlassSettings() *BreakGlassSettings {
+	return &BreakGlassSettings{
+		Keys: []BreakGlassKey{
+			{ID: "primary", Secret: base64.StdEncoding.EncodeToString([]byte(testBreakGlassSecret))},
+		},
+	}
+}
+
+// signBreakGlassToken builds a break-glass token the same way the tooling of
+// the operators does.
+func signBreakGlassToken(t *testing.T, secret string, claims BreakGlassClaims) string {
+	t.Helper()
+
+	rawClaims, err := json.Marshal(&claims)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	encodedClaims := base64.RawURLEncoding.EncodeToString(rawClaims)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encodedClaims))
+
+	return encodedClaims + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestBreakGlassSettingsValidation(t *testing.T) {
+	if err := testBreakGlassSettings().Validate(); err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+
+	cases := map[string]BreakGlassSettings{
+		"no keys": {},
+		"empty id": {
+			Keys: []BreakGlassKey{{Secret: base64.StdEncoding.EncodeToString([]byte(testBreakGlassSecret))}},
+		},
+		"duplicated id": {
+			Keys: []BreakGlassKey{
+				{ID: "a", Secret: base64.StdEncoding.EncodeToString([]byte(testBreakGlassSecret))},
+				{ID: "a", Secret: base64.StdEncoding.EncodeToString([]byte(testBreakGlassSecret))},
+			},
+		},
+		"not base64": {
+			Keys: []BreakGlassKey{{ID: "a", Secret: "not base64!"}},
+		},
+		"short secret": {
+			Keys: []BreakGlassKey{{ID: "a", Secret: base64.StdEncoding.EncodeToString([]byte("short"))}},
+		},
+	}
+
+	for name, settings := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := settings.Validate(); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestBreakGlassTokenVerification(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	valid := BreakGlassClaims{
+		KeyID:     "primary",
+		Reason:    "INC-42 outage",
+		ExpiresAt: now.Add(time.Hour),
+	}
+	settings := testBreakGlassSettings()
+
+	claims, err := settings.Verify(signBreakGlassToken(t, testBreakGlassSecret, valid), "default", "test-pod", now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if claims.Reason != valid.Reason {
+		t.Errorf("Got reason '%s' instead of '%s'", claims.Reason, valid.Reason)
+	}
+
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Minute)
+	unknownKey := valid
+	unknownKey.KeyID = "other"
+	noReason := valid
+	noReason.Reason = ""
+	noExpiration := valid
+	noExpiration.ExpiresAt = time.Time{}
+	otherNamespace := valid
+	otherNamespace.Namespace = "kube-system"
+	otherName := valid
+	otherName.Name = "other-pod"
+	forged := signBreakGlassToken(t, "not-the-right-secret-not-the-right", valid)
+
+	cases := map[string]struct {
+		token    string
+		expected string
+	}{
+		"expired":         {signBreakGlassToken(t, testBreakGlassSecret, expired), "token expired at 2026-10-19T11:59:00Z"},
+		"unknown key":     {signBreakGlassToken(t, testBreakGlassSecret, unknownKey), "token is signed with unknown key 'other'"},
+		"no reason":       {signBreakGlassToken(t, testBreakGlassSecret, noReason), "token does not have a reason"},
+		"no expiration":   {signBreakGlassToken(t, testBreakGlassSecret, noExpiration), "token does not have an expiration date"},
+		"other namespace": {signBreakGlassToken(t, testBreakGlassSecret, otherNamespace), "token is restricted to the 'kube-system' namespace"},
+		"other name":      {signBreakGlassToken(t, testBreakGlassSecret, otherName), "token is restricted to objects named 'other-pod'"},
+		"forged":          {forged, "token signature does not match key 'primary'"},
+		"tampered":        {strings.Replace(forged, ".", "x.", 1), "token claims are not base64url encoded"},
+		"no signature":    {"just-some-text", "token must be made of the claims and the signature, separated by a dot"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := settings.Verify(tc.token, "default", "test-pod", now)
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if err.Error() != tc.expected {
+				t.Errorf("Got '%s' instead of '%s'", err.Error(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestBreakGlassBypassesDenyRules(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	settings := Settings{
+		DeniedNames: denyEntries("test-pod"),
+		FreezeWindows: &FreezeSettings{
+			Absolute: []AbsoluteWindow{{Name: "incident", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+		},
+		BreakGlass: testBreakGlassSettings(),
+	}
+
+	validToken := signBreakGlassToken(t, testBreakGlassSecret, BreakGlassClaims{
+		KeyID:     "primary",
+		Reason:    "INC-42 outage",
+		ExpiresAt: now.Add(time.Hour),
+	})
+	expiredToken := signBreakGlassToken(t, testBreakGlassSecret, BreakGlassClaims{
+		KeyID:     "primary",
+		Reason:    "INC-42 outage",
+		ExpiresAt: now.Add(-time.Hour),
+	})
+
+	cases := []struct {
+		annotations map[string]string
+		accepted    bool
+		message     string
+	}{
+		{nil, false, "Pod objects cannot be changed during the 'incident' change freeze, which ends at 2026-10-19T13:00:00Z"},
+		{map[string]string{defaultBreakGlassAnnotation: validToken}, true, ""},
+		{
+			map[string]string{defaultBreakGlassAnnotation: expiredToken},
+			false,
+			"Pod objects cannot be changed during the 'incident' change freeze, which ends at 2026-10-19T13:00:00Z",
+		},
+		{
+			map[string]string{"break-glass": validToken},
+			false,
+			"Pod objects cannot be changed during the 'incident' change freeze, which ends at 2026-10-19T13:00:00Z",
+		},
+	}
+
+	for _, tc := range cases {
+		pod := testPod("test-pod", "default")
+		pod.Metadata.Annotations = tc.annotations
+
+		ev := newEvaluator()
+		ev.clock = fixedClock(now)
+		response := newRequest(t, &pod).User("alice", "sre").Settings(&settings).Validate(ev)
+
+		if response.Accepted != tc.accepted {
+			t.Errorf("annotations %v: got accepted=%v instead of %v", tc.annotations, response.Accepted, tc.accepted)
+			continue
+		}
+		if !response.Accepted && *response.Message != tc.message {
+			t.Errorf("Got '%s' instead of '%s'", *response.Message, tc.message)
+		}
+	}
+}
+
+func TestBreakGlassExpiredTokenIsIgnored(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	expiredToken := signBreakGlassToken(t, testBreakGlassSecret, BreakGlassClaims{
+		KeyID:     "primary",
+		Reason:    "INC-42 outage",
+		ExpiresAt: now.Add(-time.Hour),
+	})
+	settings := Settings{DeniedNames: denyEntries("test-pod"), BreakGlass: testBreakGlassSettings()}
+	// Pods created from a template carrying the annotation keep the token
+	// long after it expired
+	pod := testPod("test-pod", "default")
+	pod.Metadata.Annotations = map[string]string{defaultBreakGlassAnnotation: expiredToken}
+
+	cases := []struct {
+		operation string
+		request   *requestBuilder
+		accepted  bool
+		verified  bool
+	}{
+		{operationCreate, newRequest(t, &pod), false, true},
+		{operationUpdate, updateRequest(t, &pod, &pod), true, true},
+		{operationDelete, deleteRequest(t, &pod), true, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.operation, func(t *testing.T) {
+			logs := newLogSink(t)
+			ev := newEvaluator()
+			ev.clock = fixedClock(now)
+			ev.logger = logs.Logger()
+
+			response := tc.request.Settings(&settings).Validate(ev)
+			if response.Accepted != tc.accepted {
+				t.Fatalf("Got accepted=%v instead of %v, message: %v", response.Accepted, tc.accepted, response.Message)
+			}
+			if !tc.accepted && *response.Message != "The 'test-pod' name is on the deny list" {
+				t.Errorf("Unexpected message: %s", *response.Message)
+			}
+			if tc.verified {
+				logs.Find("warn", "ignoring invalid break-glass token")
+			} else {
+				logs.AssertNotLogged("break-glass")
+			}
+		})
+	}
+}
+
+func TestBreakGlassNamespaceOfTheRequest(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	token := signBreakGlassToken(t, testBreakGlassSecret, BreakGlassClaims{
+		KeyID:     "primary",
+		Reason:    "INC-42 outage",
+		ExpiresAt: now.Add(time.Hour),
+		Namespace: "shop",
+	})
+	settings := Settings{DeniedNames: denyEntries("checkout"), BreakGlass: testBreakGlassSettings()}
+	// `kubectl -n shop apply` leaves the namespace of the object empty
+	pod := corev1.Pod{
+		Metadata: &metav1.ObjectMeta{
+			Name:        "checkout",
+			Annotations: map[string]string{defaultBreakGlassAnnotation: token},
+		},
+	}
+
+	cases := []struct {
+		namespace string
+		accepted  bool
+	}{
+		{"shop", true},
+		{"default", false},
+	}
+
+	for _, tc := range cases {
+		ev := newEvaluator()
+		ev.clock = fixedClock(now)
+		response := newRequest(t, &pod).Namespace(tc.namespace).Settings(&settings).Validate(ev)
+		if response.Accepted != tc.accepted {
+			t.Errorf("namespace %s: got accepted=%v instead of %v, message: %v",
+				tc.namespace, response.Accepted, tc.accepted, response.Message)
+		}
+	}
+}
+
+func TestBreakGlassDoesNotBypassOtherRules(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	token := signBreakGlassToken(t, testBreakGlassSecret, BreakGlassClaims{
+		KeyID:     "primary",
+		Reason:    "INC-42 outage",
+		ExpiresAt: now.Add(time.Hour),
+	})
+	pod := corev1.Pod{}
+	if err := json.Unmarshal([]byte(signedPod), &pod); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	pod.Metadata.Annotations = map[string]string{defaultBreakGlassAnnotation: token}
+
+	host := newFakeHostClient()
+	trustImages(host, "registry.example.com/app:1.0")
+	resolveDigests(host, map[string]string{
+		"registry.example.com/app:1.0":   appDigest,
+		"registry.example.com/proxy:2.0": proxyDigest,
+		"docker.io/busybox:latest":       proxyDigest,
+	})
+	settings := Settings{
+		DeniedNames:     denyEntries("test-pod"),
+		BreakGlass:      testBreakGlassSettings(),
+		ImageSignatures: &ImageSignatureSettings{PubKeys: []string{"pem-key"}},
+	}
+
+	ev := newEvaluatorWithHost(host)
+	ev.clock = fixedClock(now)
+	response := newRequest(t, &pod).Settings(&settings).Validate(ev)
+	if response.Accepted {
+		t.Fatalf("Expected the unsigned images to be rejected despite the break-glass token")
+	}
+	// the deny list is bypassed, the signatures are not
+	expected := "The following images are not signed by a trusted signer: " +
+		"registry.example.com/proxy:2.0, docker.io/busybox:latest"
+	if *response.Message != expected {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expected)
+	}
+	if calls := host.Calls("oci", "v2/verify"); len(calls) == 0 {
+		t.Errorf("Expected the signatures to be verified")
+	}
+
+	settings.ImageSignatures = nil
+	settings.DigestPinning = &DigestPinningSettings{}
+	response = newRequest(t, &pod).Settings(&settings).Validate(ev)
+	mutated := corev1.Pod{}
+	decodeMutatedObject(t, &response, &mutated)
+	if image := mutated.Spec.Containers[0].Image; image != "registry.example.com/app:1.0@"+appDigest {
+		t.Errorf("Expected the images to be pinned despite the break-glass token, got %s", image)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const casesDir = "test_data/cases"
+
+// caseExpectation is the content of the `expected.json` file of a case.
+type caseExpectation struct {
+	// SettingsValid is the expected outcome of `validate_settings`.
+	SettingsValid bool `json:"settings_valid"`
+	// SettingsMessage is a regular expression matching the message of the
+	// settings validation.
+	SettingsMessage string `json:"settings_message,omitempty"`
+	// Accepted is the expected outcome of `validate`, which is not called
+	// when left out.
+	Accepted *bool `json:"accepted,omitempty"`
+	// Message is a regular expression matching the rejection message.
+	Message string `json:"message,omitempty"`
+	Code    *int   `json:"code,omitempty"`
+	// MutatedObject is the name of the file, inside of the case directory,
+	// holding the expected mutated object.
+	MutatedObject string `json:"mutated_object,omitempty"`
+}
+
+// cannedHostResponse is a response of the fake host, configured inside of
+// the optional `host.json` file of a case.
+type cannedHostResponse struct {
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// casesNow is the clock of the cases, so that their time bounds and their
+// golden outputs do not depend on the day the tests are run.
+func casesNow() time.Time {
+	return time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+}
+
+// testCase is a directory of test_data/cases, evaluated at casesNow and
+// holding:
+//
+//   - request.json: the admission request, as accepted by `kwctl run -r`
+//   - settings.json: the settings of the policy
+//   - expected.json: the expected outcome, see caseExpectation
+//   - host.json: optional canned responses of the Kubewarden host, keyed by
+//     capability namespace and operation, for example
+//     "kubernetes/get_resource"
+type testCase struct {
+	dir string
+}
+
+// name returns the name of the case, which is also the name of its
+// directory inside of test_data/golden.
+func (c testCase) name() string {
+	return filepath.Base(c.dir)
+}
+
+func (c testCase) read(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		t.Fatalf("Cannot read %s: %+v", name, err)
+	}
+
+	return data
+}
+
+func (c testCase) expectation(t *testing.T) caseExpectation {
+	t.Helper()
+
+	expected := caseExpectation{}
+	if err := json.Unmarshal(c.read(t, "expected.json"), &expected); err != nil {
+		t.Fatalf("Cannot decode expected.json: %+v", err)
+	}
+
+	return expected
+}
+
+func (c testCase) host(t *testing.T) *fakeHostClient {
+	t.Helper()
+
+	host := newFakeHostClient()
+	data, err := os.ReadFile(filepath.Join(c.dir, "host.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return host
+	}
+	if err != nil {
+		t.Fatalf("Cannot read host.json: %+v", err)
+	}
+
+	responses := map[string]cannedHostResponse{}
+	if err = json.Unmarshal(data, &responses); err != nil {
+		t.Fatalf("Cannot decode host.json: %+v", err)
+	}
+	for key, response := range responses {
+		namespace, operation, found := strings.Cut(key, "/")
+		if !found {
+			t.Fatalf("host.json: key %q must be in the <namespace>/<operation> format", key)
+		}
+		if response.Error != "" {
+			host.Fail(namespace, operation, errors.New(response.Error))
+		} else {
+			host.Respond(namespace, operation, response.Response)
+		}
+	}
+
+	return host
+}
+
+// assertMatches ensures the message matches the given regular expression.
+func assertMatches(t *testing.T, pattern string, message *string) {
+	t.Helper()
+
+	if pattern == "" {
+		return
+	}
+	if message == nil {
+		t.Fatalf("Expected a message matching %q, got none", pattern)
+	}
+	if !regexp.MustCompile(pattern).MatchString(*message) {
+		t.Errorf("Message %q does not match %q", *message, pattern)
+	}
+}
+
+func (c testCase) runValidateSettings(t *testing.T, expected *caseExpectation) {
+	ev := newEvaluator()
+	ev.clock = casesNow
+	responsePayload, err := ev.validateSettings(c.read(t, "settings.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	assertGolden(t, filepath.Join(c.name(), "validate_settings.json"), responsePayload)
+
+	var response kubewarden_protocol.SettingsValidationResponse
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	if response.Valid != expected.SettingsValid {
+		t.Errorf("Got valid=%v instead of %v, message: %v", response.Valid, expected.SettingsValid, response.Message)
+	}
+	assertMatches(t, expected.SettingsMessage, response.Message)
+}
+
+func (c testCase) runValidate(t *testing.T, expected *caseExpectation) {
+	request := kubewarden_protocol.KubernetesAdmissionRequest{}
+	if err := json.Unmarshal(c.read(t, "request.json"), &request); err != nil {
+		t.Fatalf("Cannot decode request.json: %+v", err)
+	}
+	payload, err := json.Marshal(&kubewarden_protocol.ValidationRequest{
+		Request:  request,
+		Settings: c.read(t, "settings.json"),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	ev := newEvaluatorWithHost(c.host(t))
+	ev.clock = casesNow
+	responsePayload, err := ev.validate(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	assertGolden(t, filepath.Join(c.name(), "validate.json"), responsePayload)
+
+	var response kubewarden_protocol.ValidationResponse
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	if response.Accepted != *expected.Accepted {
+		t.Errorf("Got accepted=%v instead of %v, message: %v", response.Accepted, *expected.Accepted, response.Message)
+	}
+	assertMatches(t, expected.Message, response.Message)
+	if expected.Code != nil && (response.Code == nil || int(*response.Code) != *expected.Code) {
+		t.Errorf("Got code %v instead of %d", response.Code, *expected.Code)
+	}
+
+	if expected.MutatedObject == "" {
+		if response.MutatedObject != nil {
+			t.Errorf("Unexpected mutated object: %+v", response.MutatedObject)
+		}
+		return
+	}
+	var golden interface{}
+	if err = json.Unmarshal(c.read(t, expected.MutatedObject), &golden); err != nil {
+		t.Fatalf("Cannot decode %s: %+v", expected.MutatedObject, err)
+	}
+	if !reflect.DeepEqual(response.MutatedObject, golden) {
+		mutated, _ := json.MarshalIndent(response.MutatedObject, "", "  ")
+		t.Errorf("Mutated object does not match %s, got:\n%s", expected.MutatedObject, mutated)
+	}
+}
+
+func TestCases(t *testing.T) {
+	entries, err := os.ReadDir(casesDir)
+	if err != nil {
+		t.Fatalf("Cannot list the cases: %+v", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		c := testCase{dir: filepath.Join(casesDir, entry.Name())}
+
+		t.Run(entry.Name(), func(t *testing.T) {
+			expected := c.expectation(t)
+
+			t.Run("validate_settings", func(t *testing.T) {
+				c.runValidateSettings(t, &expected)
+			})
+			if expected.Accepted != nil {
+				t.Run("validate", func(t *testing.T) {
+					c.runValidate(t, &expected)
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	onelog "github.com/francoispqt/onelog"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	collisionActionReject = "reject"
+	collisionActionWarn   = "warn"
+	operationCreate       = "CREATE"
+)
+
+// NameCollisionSettings configures the detection of objects of the same
+// kind sharing the same name across different namespaces.
+type NameCollisionSettings struct {
+	// Action is either "reject" (the default) or "warn", which only logs
+	// the collision.
+	Action string `json:"action,omitempty"`
+	// IgnoredNamespaces are not taken into account, neither when the object
+	// is created inside of them nor when looking for collisions.
+	IgnoredNamespaces []string `json:"ignored_namespaces,omitempty"`
+}
+
+// objectList is the subset of a Kubernetes list returned by the host that is
+// relevant to the policy.
+type objectList struct {
+	Items []struct {
+		Metadata *metav1.ObjectMeta `json:"metadata"`
+	} `json:"items"`
+}
+
+// Validate ensures the action is a known one.
+func (n *NameCollisionSettings) Validate() error {
+	switch n.Action {
+	case "", collisionActionReject, collisionActionWarn:
+		return nil
+	default:
+		return fmt.Errorf("name_collision.action must be either '%s' or '%s'", collisionActionReject, collisionActionWarn)
+	}
+}
+
+// IsIgnored returns true when the given namespace is not subject to the
+// collision detection.
+func (n *NameCollisionSettings) IsIgnored(namespace string) bool {
+	for _, ignored := range n.IgnoredNamespaces {
+		if ignored == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apiVersion returns the apiVersion of the given kind, as used by the
+// Kubernetes API.
+func apiVersion(kind *kubewarden_protocol.GroupVersionKind) string {
+	if kind.Group == "" {
+		return kind.Version
+	}
+
+	return kind.Group + "/" + kind.Version
+}
+
+// collidingNamespaces returns the namespaces, other than the given one, that
+// have an object with the same kind and name.
+func (ev *evaluator) collidingNamespaces(
+	settings *NameCollisionSettings,
+	kind *kubewarden_protocol.GroupVersionKind,
+	namespace, name string,
+) ([]string, error) {
+	fieldSelector := "metadata.name=" + name
+	responsePayload, err := kubernetes.ListResources(ev.capabilitiesHost(), kubernetes.ListAllResourcesRequest{
+		APIVersion:    apiVersion(kind),
+		Kind:          kind.Kind,
+		FieldSelector: &fieldSelector,
+		FieldMasks:    []string{"metadata.name", "metadata.namespace"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := objectList{}
+	if err = json.Unmarshal(responsePayload, &list); err != nil {
+		return nil, fmt.Errorf("cannot decode %s list: %w", kind.Kind, err)
+	}
+
+	namespaces := []string{}
+	for _, item := range list.Items {
+		if item.Metadata == nil || item.Metadata.Name != name {
+			continue
+		}
+		if item.Metadata.Namespace == namespace || settings.IsIgnored(item.Metadata.Namespace) {
+			continue
+		}
+		namespaces = append(namespaces, item.Metadata.Namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
+// nameCollisionViolation checks whether an object with the same kind and name
+// of the one being created already exists inside of another namespace. It
+// returns the violation message, or an empty string when the object can be
+// created. Failures to list the objects are logged and do not block the
+// request.
+func (ev *evaluator) nameCollisionViolation(
+	settings *Settings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+	metadata *metav1.ObjectMeta,
+) string {
+	collision := settings.NameCollision
+	if collision == nil || request.Operation != operationCreate {
+		return ""
+	}
+
+	namespace := requestNamespace(request, metadata)
+	if collision.IsIgnored(namespace) {
+		return ""
+	}
+
+	namespaces, err := ev.collidingNamespaces(collision, &request.Kind, namespace, metadata.Name)
+	if err != nil {
+		ev.logger.WarnWithFields("cannot look for name collisions", func(e onelog.Entry) {
+			e.String("kind", request.Kind.Kind)
+			e.String("name", metadata.Name)
+			e.String("error", err.Error())
+		})
+		return ""
+	}
+	if len(namespaces) == 0 {
+		return ""
+	}
+
+	ev.logger.WarnWithFields("name collision detected", func(e onelog.Entry) {
+		e.String("kind", request.Kind.Kind)
+		e.String("name", metadata.Name)
+		e.String("namespace", namespace)
+		e.String("colliding_namespaces", strings.Join(namespaces, ","))
+		e.String("action", collision.Action)
+	})
+	if collision.Action == collisionActionWarn || ev.isExempt(settings, &request.UserInfo) {
+		return ""
+	}
+
+	return fmt.Sprintf("The '%s' name is already used by a %s inside of the following namespaces: %s",
+		metadata.Name, request.Kind.Kind, strings.Join(namespaces, ", "))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+)
+
+const podListResponse = `{
+	"apiVersion": "v1",
+	"kind": "PodList",
+	"items": [
+		{"metadata": {"name": "test-pod", "namespace": "shop"}},
+		{"metadata": {"name": "test-pod", "namespace": "kube-system"}},
+		{"metadata": {"name": "test-pod", "namespace": "search"}}
+	]
+}`
+
+func TestNameCollisionSettingsValidation(t *testing.T) {
+	settings := Settings{NameCollision: &NameCollisionSettings{}}
+	if valid, _ := settings.Valid(); valid {
+		t.Errorf("name_collision must require context_aware")
+	}
+
+	settings.ContextAware = true
+	if valid, err := settings.Valid(); !valid {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+
+	settings.NameCollision.Action = "ignore"
+	if valid, _ := settings.Valid(); valid {
+		t.Errorf("Unknown actions must be rejected")
+	}
+}
+
+func TestNameCollisionDetection(t *testing.T) {
+	host := newFakeHostClient()
+	host.Respond("kubernetes", "list_resources_all", []byte(podListResponse))
+
+	settings := Settings{
+		ContextAware: true,
+		NameCollision: &NameCollisionSettings{
+			IgnoredNamespaces: []string{"kube-system"},
+		},
+	}
+
+	response := newRequest(t, `{"metadata": {"name": "test-pod", "namespace": "shop"}}`).Operation(operationCreate).
+		Settings(&settings).Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected approval")
+	}
+	expectedMessage := "The 'test-pod' name is already used by a Pod inside of the following namespaces: search"
+	if *response.Message != expectedMessage {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expectedMessage)
+	}
+
+	calls := host.Calls("kubernetes", "list_resources_all")
+	if len(calls) != 1 {
+		t.Fatalf("Expected one host call, got %d", len(calls))
+	}
+	request := kubernetes.ListAllResourcesRequest{}
+	if err := json.Unmarshal(calls[0].Payload, &request); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if request.APIVersion != "v1" || request.Kind != "Pod" ||
+		request.FieldSelector == nil || *request.FieldSelector != "metadata.name=test-pod" {
+		t.Errorf("Unexpected request: %s", calls[0].Payload)
+	}
+}
+
+func TestNameCollisionIsSkipped(t *testing.T) {
+	host := newFakeHostClient()
+	host.Respond("kubernetes", "list_resources_all", []byte(podListResponse))
+
+	settings := Settings{
+		ContextAware: true,
+		NameCollision: &NameCollisionSettings{
+			IgnoredNamespaces: []string{"kube-system"},
+		},
+	}
+
+	// objects created inside of ignored namespaces
+	if response := newRequest(t, `{"metadata": {"name": "test-pod", "namespace": "kube-system"}}`).Operation(operationCreate).
+		Settings(&settings).Validate(newEvaluatorWithHost(host)); !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+	// objects being updated
+	if response := newRequest(t, `{"metadata": {"name": "test-pod", "namespace": "shop"}}`).Operation(operationUpdate).
+		Settings(&settings).Validate(newEvaluatorWithHost(host)); !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+	if calls := host.Calls("kubernetes", "list_resources_all"); len(calls) != 0 {
+		t.Errorf("Expected no host calls, got %d", len(calls))
+	}
+
+	// the warn action only logs the collision
+	settings.NameCollision.Action = collisionActionWarn
+	if response := newRequest(t, `{"metadata": {"name": "test-pod", "namespace": "shop"}}`).Operation(operationCreate).
+		Settings(&settings).Validate(newEvaluatorWithHost(host)); !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+
+	// failures of the host do not block the request
+	host.Fail("kubernetes", "list_resources_all", errors.New("forbidden"))
+	settings.NameCollision.Action = collisionActionReject
+	if response := newRequest(t, `{"metadata": {"name": "test-pod", "namespace": "shop"}}`).Operation(operationCreate).
+		Settings(&settings).Validate(newEvaluatorWithHost(host)); !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+}
+
+func TestViolationsAreAggregated(t *testing.T) {
+	host := newFakeHostClient()
+	host.Respond("kubernetes", "list_resources_all", []byte(podListResponse))
+
+	settings := Settings{
+		DeniedNames:   denyEntries("test-pod"),
+		ContextAware:  true,
+		NameCollision: &NameCollisionSettings{},
+	}
+
+	response := newRequest(t, `{"metadata": {"name": "test-pod", "namespace": "shop"}}`).Operation(operationCreate).
+		Settings(&settings).Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected approval")
+	}
+	expectedMessage := "The 'test-pod' name is on the deny list; " +
+		"The 'test-pod' name is already used by a Pod inside of the following namespaces: kube-system, search"
+	if *response.Message != expectedMessage {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expectedMessage)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DenyEntry describes a single name on the deny list, together with the
+// metadata that explains why it is there.
+//
+// Inside of the settings a DenyEntry can be written either as a bare string,
+// which is the name, or as an object:
+//
+//	{"name": "foo", "reason": "...", "owner": "team-x", "ticket": "SEC-123"}
+//
+// The optional `not_before` and `expires_at` RFC 3339 timestamps limit the
+// time frame during which the entry is enforced.
+type DenyEntry struct {
+	Name      string     `json:"name"`
+	Reason    string     `json:"reason,omitempty"`
+	Owner     string     `json:"owner,omitempty"`
+	Ticket    string     `json:"ticket,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// UnmarshalJSON accepts both the bare string and the object form of a
+// DenyEntry.
+func (e *DenyEntry) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*e = DenyEntry{Name: name}
+		return nil
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return errors.New("deny entry must be either a string or an object with a 'name' field")
+	}
+
+	// use an alias type to avoid calling this method recursively
+	type denyEntry DenyEntry
+	entry := denyEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		var timeErr *time.ParseError
+		if errors.As(err, &timeErr) {
+			return fmt.Errorf("deny entry has an invalid RFC 3339 timestamp: %w", err)
+		}
+		return fmt.Errorf("deny entry is not valid: %w", err)
+	}
+	*e = DenyEntry(entry)
+
+	return nil
+}
+
+// Describe returns the details of the entry that are shown to the user when a
+// request is rejected. An empty string is returned when the entry has neither
+// a reason nor an owner.
+func (e *DenyEntry) Describe() string {
+	details := []string{}
+	if e.Reason != "" {
+		details = append(details, e.Reason)
+	}
+	if e.Owner != "" {
+		details = append(details, fmt.Sprintf("owner: %s", e.Owner))
+	}
+
+	return strings.Join(details, ", ")
+}
+
+// ActiveAt returns true when the entry has to be enforced at the given time.
+func (e *DenyEntry) ActiveAt(now time.Time) bool {
+	if e.NotBefore != nil && now.Before(*e.NotBefore) {
+		return false
+	}
+
+	return !e.ExpiredAt(now)
+}
+
+// ExpiredAt returns true when the entry is no longer enforced at the given
+// time.
+func (e *DenyEntry) ExpiredAt(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
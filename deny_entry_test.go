@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// denyEntries builds deny entries that carry only a name.
+func denyEntries(names ...string) []DenyEntry {
+	entries := make([]DenyEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, DenyEntry{Name: name})
+	}
+
+	return entries
+}
+
+func TestParsingDenyEntriesFromStringsAndObjects(t *testing.T) {
+	rawSettings := []byte(`{
+		"denied_names": [
+			"foo",
+			{"name": "bar", "reason": "migrating to baz", "owner": "team-x", "ticket": "SEC-123"}
+		]
+	}`)
+	settings := &Settings{}
+	if err := json.Unmarshal(rawSettings, settings); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	expected := []DenyEntry{
+		{Name: "foo"},
+		{Name: "bar", Reason: "migrating to baz", Owner: "team-x", Ticket: "SEC-123"},
+	}
+	if len(settings.DeniedNames) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d", len(expected), len(settings.DeniedNames))
+	}
+	for i := range expected {
+		if settings.DeniedNames[i] != expected[i] {
+			t.Errorf("Entry %d: got %+v instead of %+v", i, settings.DeniedNames[i], expected[i])
+		}
+	}
+}
+
+func TestParsingDenyEntryOfWrongType(t *testing.T) {
+	cases := map[string]string{
+		`[42]`: "deny entry must be either a string or an object with a 'name' field",
+		`[{"name": "foo", "owner": 42}]`: "deny entry is not valid: " +
+			"json: cannot unmarshal number into Go struct field denyEntry.owner of type string",
+	}
+
+	for deniedNames, expected := range cases {
+		settings := &Settings{}
+		err := json.Unmarshal([]byte(`{"denied_names": `+deniedNames+`}`), settings)
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error '%s', got %v", deniedNames, expected, err)
+		}
+	}
+}
+
+func TestDenyEntryWithoutNameIsNotValid(t *testing.T) {
+	rawSettings := []byte(`{"denied_names": [{"reason": "no name"}]}`)
+	settings := &Settings{}
+	if err := json.Unmarshal(rawSettings, settings); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	valid, err := settings.Valid()
+	if valid {
+		t.Errorf("Settings are reported as valid")
+	}
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestDenyEntryDescribe(t *testing.T) {
+	cases := []struct {
+		entry    DenyEntry
+		expected string
+	}{
+		{DenyEntry{Name: "foo"}, ""},
+		{DenyEntry{Name: "foo", Reason: "reserved"}, "reserved"},
+		{DenyEntry{Name: "foo", Owner: "team-x"}, "owner: team-x"},
+		{DenyEntry{Name: "foo", Reason: "reserved", Owner: "team-x", Ticket: "SEC-1"}, "reserved, owner: team-x"},
+	}
+
+	for _, tc := range cases {
+		if got := tc.entry.Describe(); got != tc.expected {
+			t.Errorf("Got '%s' instead of '%s'", got, tc.expected)
+		}
+	}
+}
+
+func TestParsingTimeBoundedDenyEntry(t *testing.T) {
+	rawSettings := []byte(`{
+		"denied_names": [
+			{"name": "foo", "not_before": "2026-01-01T00:00:00Z", "expires_at": "2026-02-01T00:00:00+01:00"}
+		]
+	}`)
+	settings := &Settings{}
+	if err := json.Unmarshal(rawSettings, settings); err != nil {
+		t.Fatalf("Unexpected error %+v", err)
+	}
+
+	entry := settings.DeniedNames[0]
+	if entry.NotBefore == nil || !entry.NotBefore.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected not_before: %v", entry.NotBefore)
+	}
+	if entry.ExpiresAt == nil || !entry.ExpiresAt.Equal(time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected expires_at: %v", entry.ExpiresAt)
+	}
+}
+
+func TestParsingDenyEntryWithInvalidTimestamp(t *testing.T) {
+	rawSettings := []byte(`{"denied_names": [{"name": "foo", "expires_at": "next week"}]}`)
+	settings := &Settings{}
+	err := json.Unmarshal(rawSettings, settings)
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+	if !strings.Contains(err.Error(), "RFC 3339") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestDenyEntryActiveAt(t *testing.T) {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	entry := DenyEntry{Name: "foo", NotBefore: &notBefore, ExpiresAt: &expiresAt}
+
+	cases := []struct {
+		now     time.Time
+		active  bool
+		expired bool
+	}{
+		{notBefore.Add(-time.Second), false, false},
+		{notBefore, true, false},
+		{expiresAt.Add(-time.Second), true, false},
+		{expiresAt, false, true},
+	}
+
+	for _, tc := range cases {
+		if got := entry.ActiveAt(tc.now); got != tc.active {
+			t.Errorf("ActiveAt(%s): got %v instead of %v", tc.now, got, tc.active)
+		}
+		if got := entry.ExpiredAt(tc.now); got != tc.expired {
+			t.Errorf("ExpiredAt(%s): got %v instead of %v", tc.now, got, tc.expired)
+		}
+	}
+
+	unbounded := DenyEntry{Name: "foo"}
+	if !unbounded.ActiveAt(time.Time{}) {
+		t.Errorf("Entries without time bounds must always be active")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+const (
+	failOpen   = "fail-open"
+	failClosed = "fail-closed"
+)
+
+// DenyListSource points to a ConfigMap holding additional deny entries,
+// which are merged with the ones of the settings at evaluation time.
+//
+// The value of the key can be either a JSON array, using the same format of
+// the `denied_names` setting, or a list of names separated by new lines.
+// Empty lines and lines starting with `#` are ignored.
+type DenyListSource struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+	// FailurePolicy decides what happens when the ConfigMap cannot be
+	// loaded: "fail-closed" (the default) rejects the request, "fail-open"
+	// evaluates the request using only the deny entries of the settings.
+	FailurePolicy string `json:"failure_policy,omitempty"`
+}
+
+// validateFailurePolicy ensures the given failure policy is a known one.
+func validateFailurePolicy(field, policy string) error {
+	switch policy {
+	case "", failOpen, failClosed:
+		return nil
+	default:
+		return fmt.Errorf("%s must be either '%s' or '%s'", field, failOpen, failClosed)
+	}
+}
+
+// Validate ensures the ConfigMap is fully referenced.
+func (d *DenyListSource) Validate() error {
+	if d.Namespace == "" || d.Name == "" || d.Key == "" {
+		return errors.New("deny_list_source: namespace, name and key must be provided")
+	}
+
+	return validateFailurePolicy("deny_list_source.failure_policy", d.FailurePolicy)
+}
+
+// FailOpen returns true when the request must be evaluated even if the
+// ConfigMap cannot be loaded.
+func (d *DenyListSource) FailOpen() bool {
+	return d.FailurePolicy == failOpen
+}
+
+// String returns a human readable reference to the ConfigMap key.
+func (d *DenyListSource) String() string {
+	return fmt.Sprintf("%s/%s[%s]", d.Namespace, d.Name, d.Key)
+}
+
+// parseDenyList parses the deny entries stored inside of a ConfigMap.
+func parseDenyList(content string) ([]DenyEntry, error) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "[") {
+		entries := []DenyEntry{}
+		if err := json.Unmarshal([]byte(content), &entries); err != nil {
+			return nil, fmt.Errorf("cannot parse JSON deny list: %w", err)
+		}
+		for i, entry := range entries {
+			if entry.Name == "" {
+				return nil, fmt.Errorf("entry %d: name cannot be empty", i)
+			}
+		}
+		return entries, nil
+	}
+
+	entries := []DenyEntry{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, DenyEntry{Name: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot parse deny list: %w", err)
+	}
+
+	return entries, nil
+}
+
+// loadDenyList fetches the deny entries from the ConfigMap referenced by the
+// given source.
+func (ev *evaluator) loadDenyList(source *DenyListSource) ([]DenyEntry, error) {
+	responsePayload, err := ev.getResource("v1", "ConfigMap", source.Namespace, source.Name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch ConfigMap: %w", err)
+	}
+
+	configMap := corev1.ConfigMap{}
+	if err = json.Unmarshal(responsePayload, &configMap); err != nil {
+		return nil, fmt.Errorf("cannot decode ConfigMap object: %w", err)
+	}
+
+	content, found := configMap.Data[source.Key]
+	if !found {
+		return nil, fmt.Errorf("the ConfigMap does not have the '%s' key", source.Key)
+	}
+
+	return parseDenyList(content)
+}
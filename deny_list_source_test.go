@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseDenyList(t *testing.T) {
+	cases := map[string][]DenyEntry{
+		"foo\nbar\n":                      {{Name: "foo"}, {Name: "bar"}},
+		"# reserved names\n\n  foo  \r\n": {{Name: "foo"}},
+		"":                                {},
+		` ["foo", {"name": "bar", "owner": "team-x"}]`: {{Name: "foo"}, {Name: "bar", Owner: "team-x"}},
+	}
+
+	for content, expected := range cases {
+		entries, err := parseDenyList(content)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %+v", content, err)
+		}
+		if len(entries) != len(expected) {
+			t.Fatalf("%q: got %d entries instead of %d", content, len(entries), len(expected))
+		}
+		for i := range expected {
+			if entries[i] != expected[i] {
+				t.Errorf("%q: got %+v instead of %+v", content, entries[i], expected[i])
+			}
+		}
+	}
+
+	for _, content := range []string{`["foo"`, `[{"owner": "team-x"}]`} {
+		if _, err := parseDenyList(content); err == nil {
+			t.Errorf("%q: expected an error", content)
+		}
+	}
+}
+
+func TestDenyListSourceValidation(t *testing.T) {
+	source := DenyListSource{Namespace: "kubewarden", Name: "deny-list", Key: "names"}
+
+	settings := Settings{DenyListSource: &source}
+	if valid, _ := settings.Valid(); valid {
+		t.Errorf("deny_list_source must require context_aware")
+	}
+
+	settings.ContextAware = true
+	if valid, err := settings.Valid(); !valid {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+
+	source.FailurePolicy = "ignore"
+	if valid, _ := settings.Valid(); valid {
+		t.Errorf("Unknown failure policies must be rejected")
+	}
+
+	source.FailurePolicy = failOpen
+	source.Key = ""
+	if valid, _ := settings.Valid(); valid {
+		t.Errorf("The key must be required")
+	}
+}
+
+// denyListSourceSettings loads the deny list from the kubewarden/deny-list
+// ConfigMap too.
+func denyListSourceSettings(failurePolicy string) *Settings {
+	return &Settings{
+		DeniedNames:  denyEntries("foo"),
+		ContextAware: true,
+		DenyListSource: &DenyListSource{
+			Namespace:     "kubewarden",
+			Name:          "deny-list",
+			Key:           "names",
+			FailurePolicy: failurePolicy,
+		},
+	}
+}
+
+func TestDenyListIsMergedWithTheConfigMap(t *testing.T) {
+	host := newFakeHostClient()
+	host.AddResource(t, "v1", "ConfigMap", "kubewarden", "deny-list", corev1.ConfigMap{
+		Metadata: &metav1.ObjectMeta{Name: "deny-list", Namespace: "kubewarden"},
+		Data:     map[string]string{"names": "bar\ntest-pod\n"},
+	})
+
+	response := newRequest(t, testPod("test-pod", "default")).Settings(denyListSourceSettings("")).
+		Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected approval")
+	}
+	expectedMessage := "The 'test-pod' name is on the deny list"
+	if *response.Message != expectedMessage {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expectedMessage)
+	}
+}
+
+func TestDenyListWithMissingKey(t *testing.T) {
+	host := newFakeHostClient()
+	host.AddResource(t, "v1", "ConfigMap", "kubewarden", "deny-list", corev1.ConfigMap{
+		Metadata: &metav1.ObjectMeta{Name: "deny-list", Namespace: "kubewarden"},
+		Data:     map[string]string{"other": "test-pod"},
+	})
+
+	response := newRequest(t, testPod("test-pod", "default")).Settings(denyListSourceSettings(failClosed)).
+		Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected approval")
+	}
+	expectedMessage := "Cannot load the deny list from ConfigMap kubewarden/deny-list[names]: " +
+		"the ConfigMap does not have the 'names' key"
+	if *response.Message != expectedMessage {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expectedMessage)
+	}
+}
+
+func TestDenyListSourceFailurePolicy(t *testing.T) {
+	host := newFakeHostClient()
+	host.Fail("kubernetes", "get_resource", errors.New("connection refused"))
+
+	response := newRequest(t, testPod("test-pod", "default")).Settings(denyListSourceSettings(failClosed)).
+		Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected approval")
+	}
+	if response.Code == nil || *response.Code != httpInternalServerErrorStatusCode {
+		t.Errorf("Unexpected code: %v", response.Code)
+	}
+
+	response = newRequest(t, testPod("test-pod", "default")).Settings(denyListSourceSettings(failOpen)).
+		Validate(newEvaluatorWithHost(host))
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	onelog "github.com/francoispqt/onelog"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/manifest_digest"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// DigestPinningSettings enables the mutation that pins the image tags used by
+// Pods and by the controllers of Pods to their digests. The digests are
+// resolved through the Kubewarden host.
+type DigestPinningSettings struct {
+	// FailurePolicy decides what happens when the digest of an image cannot
+	// be resolved: "fail-closed" (the default) rejects the request,
+	// "fail-open" leaves the image untouched.
+	FailurePolicy string `json:"failure_policy,omitempty"`
+}
+
+// Validate ensures the failure policy is a known one.
+func (d *DigestPinningSettings) Validate() error {
+	return validateFailurePolicy("digest_pinning.failure_policy", d.FailurePolicy)
+}
+
+// FailOpen returns true when images whose digest cannot be resolved are left
+// untouched.
+func (d *DigestPinningSettings) FailOpen() bool {
+	return d.FailurePolicy == failOpen
+}
+
+// isPinned returns true when the image is already referenced by digest.
+func isPinned(image string) bool {
+	return strings.Contains(image, "@")
+}
+
+// podTemplate is the template of the Pods created by a controller.
+type podTemplate struct {
+	Spec *corev1.PodSpec `json:"spec"`
+}
+
+// podControllerSpec is the spec of a controller of Pods. CronJobs hold the
+// spec of the Jobs they create inside of the job template.
+type podControllerSpec struct {
+	Template    *podTemplate `json:"template"`
+	JobTemplate *struct {
+		Spec *podControllerSpec `json:"spec"`
+	} `json:"jobTemplate"`
+}
+
+// podController is decoded instead of the typed objects to cope with
+// controllers lacking some of the parent fields of the Pod spec.
+type podController struct {
+	Spec *podControllerSpec `json:"spec"`
+}
+
+// podSpec returns the Pod spec of the object being validated, or nil when it
+// has none. Objects that are not controllers of Pods are treated as Pods.
+func podSpec(validationRequest *kubewarden_protocol.ValidationRequest, pod *corev1.Pod) (*corev1.PodSpec, error) {
+	switch validationRequest.Request.Kind.Kind {
+	case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet", "ReplicationController", "Job", "CronJob":
+	default:
+		return pod.Spec, nil
+	}
+
+	controller := podController{}
+	if err := json.Unmarshal(validationRequest.Request.Object, &controller); err != nil {
+		return nil, err
+	}
+
+	spec := controller.Spec
+	if spec != nil && spec.JobTemplate != nil {
+		spec = spec.JobTemplate.Spec
+	}
+	if spec == nil || spec.Template == nil {
+		return nil, nil //nolint:nilnil // controllers without a template have no Pod spec
+	}
+
+	return spec.Template.Spec, nil
+}
+
+// pinImages rewrites the images of the given spec, appending the digest to
+// the ones that are not pinned yet. It returns true when at least one image
+// has been changed.
+func (ev *evaluator) pinImages(settings *DigestPinningSettings, spec *corev1.PodSpec) (bool, error) {
+	if spec == nil {
+		return false, nil
+	}
+
+	digests := map[string]string{}
+	changed := false
+	pin := func(image string) (string, error) {
+		if image == "" || isPinned(image) {
+			return image, nil
+		}
+		digest, found := digests[image]
+		if !found {
+			var err error
+			digest, err = manifest_digest.GetOCIManifestDigest(ev.capabilitiesHost(), image)
+			if err != nil || digest == "" {
+				if err == nil {
+					err = fmt.Errorf("empty digest")
+				}
+				if !settings.FailOpen() {
+					return "", fmt.Errorf("cannot resolve the digest of image %s: %w", image, err)
+				}
+				ev.logger.WarnWithFields("cannot resolve image digest, leaving the image untouched", func(e onelog.Entry) {
+					e.String("image", image)
+					e.String("error", err.Error())
+				})
+				return image, nil
+			}
+			digests[image] = digest
+		}
+		changed = true
+
+		return image + "@" + digest, nil
+	}
+
+	var err error
+	for _, container := range spec.Containers {
+		if container.Image, err = pin(container.Image); err != nil {
+			return false, err
+		}
+	}
+	for _, container := range spec.InitContainers {
+		if container.Image, err = pin(container.Image); err != nil {
+			return false, err
+		}
+	}
+
+	return changed, nil
+}
+
+// pinDigests accepts the request, mutating the object when it is being
+// created and some of its images are not pinned to a digest.
+func (ev *evaluator) pinDigests(
+	settings *DigestPinningSettings,
+	validationRequest *kubewarden_protocol.ValidationRequest,
+	spec *corev1.PodSpec,
+) ([]byte, error) {
+	// The images of existing Pods cannot be changed, and the ephemeral
+	// containers are only added on UPDATE: objects are pinned on creation
+	if validationRequest.Request.Operation != operationCreate {
+		return kubewarden.AcceptRequest()
+	}
+
+	changed, err := ev.pinImages(settings, spec)
+	if err != nil {
+		return kubewarden.RejectRequest(
+			kubewarden.Message(fmt.Sprintf("Cannot pin the images to their digests: %s", err.Error())),
+			kubewarden.Code(httpInternalServerErrorStatusCode))
+	}
+	if !changed {
+		return kubewarden.AcceptRequest()
+	}
+
+	ev.logger.InfoWithFields("pinning images to their digests", func(e onelog.Entry) {
+		e.String("kind", validationRequest.Request.Kind.Kind)
+		e.String("name", validationRequest.Request.Name)
+		e.String("namespace", validationRequest.Request.Namespace)
+	})
+
+	return kubewarden.MutatePodSpecFromRequest(*validationRequest, *spec)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/manifest_digest"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	appDigest   = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	proxyDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+const deploymentObject = `{
+	"apiVersion": "apps/v1",
+	"kind": "Deployment",
+	"metadata": {"name": "shop", "namespace": "shop"},
+	"spec": {
+		"selector": {"matchLabels": {"app": "shop"}},
+		"template": {
+			"metadata": {"labels": {"app": "shop"}},
+			"spec": {"containers": [{"name": "app", "image": "registry.example.com/app:1.0"}]}
+		}
+	}
+}`
+
+// resolveDigests makes the manifest digest capability resolve the given
+// images, and fail for all the other ones.
+func resolveDigests(host *fakeHostClient, digests map[string]string) {
+	host.Handle("oci", "v1/manifest_digest", func(payload []byte) ([]byte, error) {
+		var image string
+		if err := json.Unmarshal(payload, &image); err != nil {
+			return nil, err
+		}
+		digest, found := digests[image]
+		if !found {
+			return nil, errors.New("manifest unknown")
+		}
+		return json.Marshal(manifest_digest.OciManifestResponse{Digest: digest})
+	})
+}
+
+// decodeMutatedObject decodes the mutated object of the response into the
+// given object.
+func decodeMutatedObject(t *testing.T, response *kubewarden_protocol.ValidationResponse, object interface{}) {
+	t.Helper()
+
+	if !response.Accepted || response.MutatedObject == nil {
+		t.Fatalf("Expected the request to be mutated, got: %+v", response)
+	}
+	raw, err := json.Marshal(response.MutatedObject)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if err = json.Unmarshal(raw, object); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+}
+
+func TestIsPinned(t *testing.T) {
+	cases := map[string]bool{
+		"busybox":                                   false,
+		"registry.example.com:5000/app:1.0":         false,
+		"registry.example.com/app@" + appDigest:     true,
+		"registry.example.com/app:1.0@" + appDigest: true,
+	}
+
+	for image, expected := range cases {
+		if isPinned(image) != expected {
+			t.Errorf("isPinned(%q) should be %v", image, expected)
+		}
+	}
+}
+
+func TestPodSpecOfControllers(t *testing.T) {
+	cases := []struct {
+		kind   string
+		object string
+		image  string
+	}{
+		{"Deployment", deploymentObject, "registry.example.com/app:1.0"},
+		{"CronJob", `{"spec": {"jobTemplate": {"spec": {"template": {"spec": {"containers": [{"image": "cron:1"}]}}}}}}`, "cron:1"},
+		{"Job", `{"spec": {"template": {"spec": {"containers": [{"image": "job:1"}]}}}}`, "job:1"},
+		{"Deployment", `{"spec": {"replicas": 1}}`, ""},
+		{"CronJob", `{"spec": {"jobTemplate": {}}}`, ""},
+		{"StatefulSet", `{}`, ""},
+	}
+
+	for _, tc := range cases {
+		validationRequest := newRequest(t, tc.object).Kind("apps", "v1", tc.kind).Build()
+		spec, err := podSpec(&validationRequest, &corev1.Pod{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %+v", tc.object, err)
+		}
+		if tc.image == "" {
+			if spec != nil {
+				t.Errorf("%s: expected no Pod spec, got %+v", tc.object, spec)
+			}
+			continue
+		}
+		if spec == nil || len(spec.Containers) != 1 || spec.Containers[0].Image != tc.image {
+			t.Errorf("%s: unexpected Pod spec %+v", tc.object, spec)
+		}
+	}
+}
+
+func TestDigestPinningPod(t *testing.T) {
+	host := newFakeHostClient()
+	resolveDigests(host, map[string]string{
+		"registry.example.com/app:1.0":   appDigest,
+		"registry.example.com/proxy:2.0": proxyDigest,
+	})
+	object := `{
+		"metadata": {"name": "test-pod", "namespace": "shop"},
+		"spec": {
+			"containers": [
+				{"name": "app", "image": "registry.example.com/app:1.0"},
+				{"name": "proxy", "image": "registry.example.com/proxy:2.0"}
+			],
+			"initContainers": [
+				{"name": "init", "image": "registry.example.com/app:1.0"},
+				{"name": "pinned", "image": "registry.example.com/tools@` + proxyDigest + `"}
+			]
+		}
+	}`
+	settings := &Settings{DeniedNames: []DenyEntry{}, DigestPinning: &DigestPinningSettings{}}
+
+	response := newRequest(t, object).Settings(settings).Validate(newEvaluatorWithHost(host))
+
+	pod := corev1.Pod{}
+	decodeMutatedObject(t, &response, &pod)
+	expected := []string{
+		"registry.example.com/app:1.0@" + appDigest,
+		"registry.example.com/proxy:2.0@" + proxyDigest,
+		"registry.example.com/app:1.0@" + appDigest,
+		"registry.example.com/tools@" + proxyDigest,
+	}
+	images := []string{
+		pod.Spec.Containers[0].Image, pod.Spec.Containers[1].Image,
+		pod.Spec.InitContainers[0].Image, pod.Spec.InitContainers[1].Image,
+	}
+	for i := range expected {
+		if images[i] != expected[i] {
+			t.Errorf("Got image %s instead of %s", images[i], expected[i])
+		}
+	}
+
+	// every distinct image is resolved once, pinned images are never resolved
+	if calls := host.Calls("oci", "v1/manifest_digest"); len(calls) != 2 {
+		t.Errorf("Expected 2 digest lookups, got %d", len(calls))
+	}
+}
+
+func TestDigestPinningDeployment(t *testing.T) {
+	host := newFakeHostClient()
+	resolveDigests(host, map[string]string{"registry.example.com/app:1.0": appDigest})
+	settings := &Settings{DeniedNames: []DenyEntry{}, DigestPinning: &DigestPinningSettings{}}
+
+	response := newRequest(t, deploymentObject).Settings(settings).Validate(newEvaluatorWithHost(host))
+
+	deployment := appsv1.Deployment{}
+	decodeMutatedObject(t, &response, &deployment)
+	image := deployment.Spec.Template.Spec.Containers[0].Image
+	if image != "registry.example.com/app:1.0@"+appDigest {
+		t.Errorf("Unexpected image %s", image)
+	}
+	if deployment.Metadata.Name != "shop" || deployment.Spec.Selector == nil {
+		t.Errorf("The rest of the Deployment should not be changed: %+v", deployment)
+	}
+}
+
+func TestDigestPinningNothingToPin(t *testing.T) {
+	host := newFakeHostClient()
+	object := `{"metadata": {"name": "test-pod"}, "spec": {"containers": [{"name": "app", "image": "busybox@` + appDigest + `"}]}}`
+	settings := &Settings{DeniedNames: []DenyEntry{}, DigestPinning: &DigestPinningSettings{}}
+
+	response := newRequest(t, object).Settings(settings).Validate(newEvaluatorWithHost(host))
+	if !response.Accepted || response.MutatedObject != nil {
+		t.Errorf("Expected the request to be accepted without mutations, got: %+v", response)
+	}
+	if calls := host.Calls("oci", "v1/manifest_digest"); len(calls) != 0 {
+		t.Errorf("Unexpected digest lookups: %+v", calls)
+	}
+}
+
+func TestDigestPinningFailurePolicy(t *testing.T) {
+	object := `{
+		"metadata": {"name": "test-pod"},
+		"spec": {"containers": [
+			{"name": "app", "image": "registry.example.com/app:1.0"},
+			{"name": "missing", "image": "registry.example.com/missing:1.0"}
+		]}
+	}`
+
+	t.Run("fail-closed", func(t *testing.T) {
+		host := newFakeHostClient()
+		resolveDigests(host, map[string]string{"registry.example.com/app:1.0": appDigest})
+		settings := &Settings{DeniedNames: []DenyEntry{}, DigestPinning: &DigestPinningSettings{}}
+
+		response := newRequest(t, object).Settings(settings).Validate(newEvaluatorWithHost(host))
+		if response.Accepted {
+			t.Fatalf("Unexpected acceptance")
+		}
+		expected := "Cannot pin the images to their digests: " +
+			"cannot resolve the digest of image registry.example.com/missing:1.0: manifest unknown"
+		if *response.Message != expected {
+			t.Errorf("Got '%s' instead of '%s'", *response.Message, expected)
+		}
+		if response.Code == nil || *response.Code != httpInternalServerErrorStatusCode {
+			t.Errorf("Unexpected code: %v", response.Code)
+		}
+	})
+
+	t.Run("fail-open", func(t *testing.T) {
+		host := newFakeHostClient()
+		resolveDigests(host, map[string]string{"registry.example.com/app:1.0": appDigest})
+		settings := &Settings{
+			DeniedNames:   []DenyEntry{},
+			DigestPinning: &DigestPinningSettings{FailurePolicy: failOpen},
+		}
+
+		response := newRequest(t, object).Settings(settings).Validate(newEvaluatorWithHost(host))
+
+		pod := corev1.Pod{}
+		decodeMutatedObject(t, &response, &pod)
+		if pod.Spec.Containers[0].Image != "registry.example.com/app:1.0@"+appDigest {
+			t.Errorf("Unexpected image %s", pod.Spec.Containers[0].Image)
+		}
+		if pod.Spec.Containers[1].Image != "registry.example.com/missing:1.0" {
+			t.Errorf("Unresolved image should be left untouched, got %s", pod.Spec.Containers[1].Image)
+		}
+	})
+}
+
+func TestDigestPinningAfterViolations(t *testing.T) {
+	host := newFakeHostClient()
+	settings := &Settings{DeniedNames: denyEntries("shop"), DigestPinning: &DigestPinningSettings{}}
+
+	response := newRequest(t, deploymentObject).Settings(settings).Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected acceptance")
+	}
+	if calls := host.Calls("oci", "v1/manifest_digest"); len(calls) != 0 {
+		t.Errorf("Rejected requests should not be mutated: %+v", calls)
+	}
+}
+
+func TestDigestPinningSettings(t *testing.T) {
+	settings := Settings{DeniedNames: []DenyEntry{}, DigestPinning: &DigestPinningSettings{FailurePolicy: "ignore"}}
+
+	valid, err := settings.Valid()
+	expected := "digest_pinning.failure_policy must be either 'fail-open' or 'fail-closed'"
+	if valid || err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got valid: %v, error: %v", expected, valid, err)
+	}
+}
+
+func TestDigestPinningSkipsUpdates(t *testing.T) {
+	host := newFakeHostClient()
+	resolveDigests(host, map[string]string{
+		"registry.example.com/app:1.0": appDigest,
+		"docker.io/busybox:latest":     proxyDigest,
+	})
+	settings := &Settings{DeniedNames: []DenyEntry{}, DigestPinning: &DigestPinningSettings{}}
+	oldPod := `{"metadata": {"name": "test-pod"}, "spec": {"containers": [{"name": "app", "image": "registry.example.com/app:1.0"}]}}`
+	// kubectl debug adds an ephemeral container through an UPDATE
+	newPod := `{
+		"metadata": {"name": "test-pod"},
+		"spec": {
+			"containers": [{"name": "app", "image": "registry.example.com/app:1.0"}],
+			"ephemeralContainers": [{"name": "debug", "image": "docker.io/busybox:latest"}]
+		}
+	}`
+
+	for _, subResource := range []string{"", "ephemeralcontainers"} {
+		response := updateRequest(t, oldPod, newPod).SubResource(subResource).Settings(settings).
+			Validate(newEvaluatorWithHost(host))
+		if !response.Accepted || response.MutatedObject != nil {
+			t.Errorf("subresource %q: expected the update to be accepted without mutations, got: %+v",
+				subResource, response)
+		}
+	}
+	if calls := host.Calls("oci", "v1/manifest_digest"); len(calls) != 0 {
+		t.Errorf("Unexpected digest lookups: %+v", calls)
+	}
+}
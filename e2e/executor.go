@@ -0,0 +1,149 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// Response is the response of the policy, in the format printed by
+// `kwctl run`.
+type Response map[string]interface{}
+
+// Field returns the value of the field at the given path, the keys of
+// nested objects being joined by dots.
+func (r Response) Field(path string) (interface{}, bool) {
+	var value interface{} = map[string]interface{}(r)
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// NewResponse converts the response of the `validate` waPC function into the
+// format printed by `kwctl run`.
+func NewResponse(uid string, validationResponse *kubewarden_protocol.ValidationResponse) Response {
+	response := Response{
+		"uid":     uid,
+		"allowed": validationResponse.Accepted,
+	}
+	if validationResponse.Message != nil || validationResponse.Code != nil {
+		status := map[string]interface{}{}
+		if validationResponse.Message != nil {
+			status["message"] = *validationResponse.Message
+		}
+		if validationResponse.Code != nil {
+			status["code"] = float64(*validationResponse.Code)
+		}
+		response["status"] = status
+	}
+
+	return response
+}
+
+// ErrUnsupported is returned by the executors that cannot evaluate a
+// scenario, which is then skipped.
+var ErrUnsupported = errors.New("scenario not supported by the executor")
+
+// Executor evaluates the scenarios.
+type Executor interface {
+	// Name identifies the executor inside of the test names.
+	Name() string
+	// Evaluate evaluates the request of the scenario with its settings.
+	// The paths of the scenario are relative to the given directory.
+	Evaluate(root string, scenario *Scenario) (Response, error)
+}
+
+// KwctlExecutor evaluates the scenarios by running `kwctl run` against the
+// annotated policy.
+type KwctlExecutor struct {
+	binary string
+	policy string
+}
+
+// NewKwctlExecutor creates an executor of the given policy, returning an
+// error when either kwctl or the policy are missing.
+func NewKwctlExecutor(policy string) (*KwctlExecutor, error) {
+	binary, err := exec.LookPath("kwctl")
+	if err != nil {
+		return nil, fmt.Errorf("kwctl is not installed: %w", err)
+	}
+	if _, err = os.Stat(policy); err != nil {
+		return nil, fmt.Errorf("cannot find the policy, run `make annotated-policy.wasm`: %w", err)
+	}
+
+	return &KwctlExecutor{binary: binary, policy: policy}, nil
+}
+
+func (e *KwctlExecutor) Name() string {
+	return "kwctl"
+}
+
+func (e *KwctlExecutor) Evaluate(root string, scenario *Scenario) (Response, error) {
+	if scenario.Now != nil {
+		return nil, fmt.Errorf("%w: kwctl evaluates the requests at the current time", ErrUnsupported)
+	}
+
+	//nolint:gosec // the arguments come from the scenarios of the repository
+	cmd := exec.Command(e.binary, "run", e.policy,
+		"-r", filepath.Join(root, scenario.Request),
+		"--settings-json", string(scenario.SettingsOrDefault()))
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("kwctl run failed: %w, output:\n%s", err, stderr.String())
+	}
+
+	// the response is printed after the logs of the policy
+	start := bytes.LastIndex(stdout.Bytes(), []byte("\n{"))
+	if start < 0 {
+		start = bytes.IndexByte(stdout.Bytes(), '{')
+	}
+	if start < 0 {
+		return nil, errors.New("kwctl run printed no response:\n" + stdout.String())
+	}
+	response := Response{}
+	if err := json.Unmarshal(stdout.Bytes()[start:], &response); err != nil {
+		return nil, fmt.Errorf("cannot decode the response of kwctl: %w, output:\n%s", err, stdout.String())
+	}
+
+	return response, nil
+}
+
+// ProtocolVersion returns the protocol version of the policy, as reported by
+// `kwctl inspect`. `kwctl annotate` computes it by calling the
+// `protocol_version` waPC function of the module, which the SDK registers
+// only when building with TinyGo.
+func (e *KwctlExecutor) ProtocolVersion() (string, error) {
+	//nolint:gosec // the policy is the one built by the repository
+	output, err := exec.Command(e.binary, "inspect", "--output", "yaml", e.policy).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("kwctl inspect failed: %w, output:\n%s", err, output)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if value, found := strings.CutPrefix(strings.TrimSpace(line), "protocolVersion:"); found {
+			return strings.Trim(strings.TrimSpace(value), `"'`), nil
+		}
+	}
+
+	return "", errors.New("kwctl inspect reported no protocol version:\n" + string(output))
+}
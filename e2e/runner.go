@@ -0,0 +1,98 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+// FieldDiff is a field of the response not matching the expectation of a
+// scenario.
+type FieldDiff struct {
+	Path     string
+	Expected interface{}
+	Actual   interface{}
+	// Missing is set when the response has no such field.
+	Missing bool
+}
+
+// Diff compares the response with the expected fields, sorted by path.
+func Diff(expected map[string]interface{}, response Response) []FieldDiff {
+	paths := make([]string, 0, len(expected))
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	diffs := []FieldDiff{}
+	for _, path := range paths {
+		actual, found := response.Field(path)
+		if !found {
+			diffs = append(diffs, FieldDiff{Path: path, Expected: expected[path], Missing: true})
+			continue
+		}
+		if !reflect.DeepEqual(actual, expected[path]) {
+			diffs = append(diffs, FieldDiff{Path: path, Expected: expected[path], Actual: actual})
+		}
+	}
+
+	return diffs
+}
+
+// FormatDiff renders the differences as a table, one field per line.
+func FormatDiff(diffs []FieldDiff) string {
+	builder := strings.Builder{}
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0) //nolint:mnd // padding of the columns
+	fmt.Fprintln(writer, "FIELD\tEXPECTED\tACTUAL")
+	for _, diff := range diffs {
+		actual := "<missing>"
+		if !diff.Missing {
+			actual = formatValue(diff.Actual)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", diff.Path, formatValue(diff.Expected), actual)
+	}
+	writer.Flush()
+
+	return builder.String()
+}
+
+func formatValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(data)
+}
+
+// Run evaluates the scenarios with the executor, as subtests named after
+// the executor and the scenario. The paths of the scenarios are relative to
+// the given directory.
+func Run(t *testing.T, root string, executor Executor, scenarios []Scenario) {
+	t.Helper()
+
+	for _, scenario := range scenarios {
+		t.Run(executor.Name()+"/"+scenario.Name, func(t *testing.T) {
+			response, err := executor.Evaluate(root, &scenario)
+			if errors.Is(err, ErrUnsupported) {
+				t.Skipf("Skipping %q: %+v", scenario.Description, err)
+			}
+			if err != nil {
+				t.Fatalf("Cannot evaluate %q: %+v", scenario.Description, err)
+			}
+
+			if diffs := Diff(scenario.Expected, response); len(diffs) > 0 {
+				raw, _ := json.MarshalIndent(response, "", "  ")
+				t.Errorf("%s: the response does not match the expectation\n%s\nResponse:\n%s",
+					scenario.Description, FormatDiff(diffs), raw)
+			}
+		})
+	}
+}
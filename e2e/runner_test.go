@@ -0,0 +1,83 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"strings"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestNewResponse(t *testing.T) {
+	message := "The 'test-pod' name is on the deny list"
+	code := uint16(400)
+	response := NewResponse("uid-1", &kubewarden_protocol.ValidationResponse{
+		Accepted: false,
+		Message:  &message,
+		Code:     &code,
+	})
+
+	expected := map[string]interface{}{
+		"uid":            "uid-1",
+		"allowed":        false,
+		"status.message": message,
+		"status.code":    float64(400),
+	}
+	if diffs := Diff(expected, response); len(diffs) > 0 {
+		t.Errorf("Unexpected response:\n%s", FormatDiff(diffs))
+	}
+
+	if _, found := NewResponse("uid-2", &kubewarden_protocol.ValidationResponse{Accepted: true}).Field("status"); found {
+		t.Error("Accepted responses without a message must have no status")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	response := Response{
+		"allowed": true,
+		"status":  map[string]interface{}{"message": "denied"},
+	}
+	expected := map[string]interface{}{
+		"allowed":        false,
+		"status.message": "denied",
+		"status.code":    float64(400),
+		"allowed.nested": true,
+	}
+
+	diffs := Diff(expected, response)
+	if len(diffs) != 3 {
+		t.Fatalf("Expected 3 differences, got %+v", diffs)
+	}
+	if diffs[0].Path != "allowed" || diffs[0].Actual != true || diffs[0].Missing {
+		t.Errorf("Unexpected difference %+v", diffs[0])
+	}
+	if diffs[1].Path != "allowed.nested" || !diffs[1].Missing {
+		t.Errorf("Unexpected difference %+v", diffs[1])
+	}
+	if diffs[2].Path != "status.code" || !diffs[2].Missing {
+		t.Errorf("Unexpected difference %+v", diffs[2])
+	}
+
+	formatted := FormatDiff(diffs)
+	for _, line := range []string{"FIELD", "allowed         false     true", "status.code     400       <missing>"} {
+		if !strings.Contains(formatted, line) {
+			t.Errorf("%q not found inside of:\n%s", line, formatted)
+		}
+	}
+}
+
+func TestLoadScenarios(t *testing.T) {
+	scenarios, err := LoadScenarios("scenarios")
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("No scenario found")
+	}
+	for i := 1; i < len(scenarios); i++ {
+		if scenarios[i-1].Name >= scenarios[i].Name {
+			t.Errorf("The scenarios are not sorted: %s, %s", scenarios[i-1].Name, scenarios[i].Name)
+		}
+	}
+}
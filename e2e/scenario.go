@@ -0,0 +1,90 @@
+//go:build e2e
+
+// Package e2e runs end-to-end scenarios against the policy. Each scenario is
+// a JSON spec, inside of the scenarios directory, made of the settings of
+// the policy, the admission request to evaluate and the fields expected
+// inside of the response:
+//
+//	{
+//	  "settings": {"denied_names": ["foo", "test-pod"]},
+//	  "request": "test_data/pod.json",
+//	  "expected": {"allowed": false, "status.message": "The 'test-pod' name is on the deny list"}
+//	}
+//
+// The scenarios are evaluated by an Executor, which can run the policy in
+// process or through kwctl. The responses are compared in the format printed
+// by `kwctl run`, the one of a Kubernetes AdmissionResponse.
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ScenariosDir is the directory holding the scenarios, relative to the root
+// of the repository.
+const ScenariosDir = "e2e/scenarios"
+
+// Scenario is an end-to-end scenario, decoded from its spec.
+type Scenario struct {
+	// Name is the name of the spec file, without extension.
+	Name string `json:"-"`
+	// Description explains what the scenario is about.
+	Description string `json:"description"`
+	// Settings are the settings of the policy, the default ones are used
+	// when left out.
+	Settings json.RawMessage `json:"settings,omitempty"`
+	// Now is the time at which the request is evaluated, the current time is
+	// used when left out. The executors that cannot fake the time skip the
+	// scenarios setting it.
+	Now *time.Time `json:"now,omitempty"`
+	// Request is the path of the file holding the admission request,
+	// relative to the root of the repository.
+	Request string `json:"request"`
+	// Expected maps the path of a field of the response, the keys of nested
+	// objects being joined by dots, to its expected value.
+	Expected map[string]interface{} `json:"expected"`
+}
+
+// LoadScenarios decodes all the specs found inside of the given directory,
+// sorted by name.
+func LoadScenarios(dir string) ([]Scenario, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot list the scenarios: %w", err)
+	}
+	sort.Strings(paths)
+
+	scenarios := make([]Scenario, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", path, err)
+		}
+		scenario := Scenario{Name: strings.TrimSuffix(filepath.Base(path), ".json")}
+		if err = json.Unmarshal(data, &scenario); err != nil {
+			return nil, fmt.Errorf("cannot decode %s: %w", path, err)
+		}
+		if scenario.Request == "" || len(scenario.Expected) == 0 {
+			return nil, fmt.Errorf("%s: both request and expected are required", path)
+		}
+		scenarios = append(scenarios, scenario)
+	}
+
+	return scenarios, nil
+}
+
+// SettingsOrDefault returns the settings of the scenario, or the empty
+// settings when the scenario has none.
+func (s *Scenario) SettingsOrDefault() json.RawMessage {
+	if len(s.Settings) == 0 {
+		return json.RawMessage("{}")
+	}
+
+	return s.Settings
+}
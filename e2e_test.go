@@ -0,0 +1,94 @@
+//go:build e2e
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubewarden/go-policy-template/e2e"
+	"github.com/kubewarden/policy-sdk-go/constants"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const annotatedPolicy = "annotated-policy.wasm"
+
+//nolint:gochecknoglobals // flags of the tests must be registered before they are parsed
+var requireKwctl = flag.Bool("require-kwctl", false, "fail when the scenarios cannot be evaluated by kwctl")
+
+// nativeExecutor evaluates the e2e scenarios in process, through the waPC
+// functions registered by the policy. It lives here because the policy is
+// a main package, which cannot be imported by the e2e package.
+type nativeExecutor struct {
+	harness *wapcHarness
+}
+
+func (e *nativeExecutor) Name() string {
+	return "native"
+}
+
+func (e *nativeExecutor) Evaluate(root string, scenario *e2e.Scenario) (e2e.Response, error) {
+	data, err := os.ReadFile(filepath.Join(root, scenario.Request))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the request: %w", err)
+	}
+	request := kubewarden_protocol.KubernetesAdmissionRequest{}
+	if err = json.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("cannot decode the request: %w", err)
+	}
+
+	harness := e.harness
+	if scenario.Now != nil {
+		harness = harness.at(*scenario.Now)
+	}
+	response, err := harness.evaluate(&kubewarden_protocol.ValidationRequest{
+		Request:  request,
+		Settings: scenario.SettingsOrDefault(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e2e.NewResponse(request.Uid, &response), nil
+}
+
+// TestE2E runs the scenarios of e2e/scenarios in process and against the
+// annotated policy, through kwctl, which also reports the protocol version
+// exported by the module. The kwctl scenarios are skipped when
+// either kwctl or the policy are missing, unless -require-kwctl is given:
+//
+//	go test -tags e2e -run TestE2E . -require-kwctl
+func TestE2E(t *testing.T) {
+	scenarios, err := e2e.LoadScenarios(e2e.ScenariosDir)
+	if err != nil {
+		t.Fatalf("Cannot load the scenarios: %+v", err)
+	}
+
+	e2e.Run(t, ".", &nativeExecutor{harness: newWapcHarness()}, scenarios)
+
+	kwctl, err := e2e.NewKwctlExecutor(annotatedPolicy)
+	if err != nil {
+		t.Run("kwctl", func(t *testing.T) {
+			if *requireKwctl {
+				t.Fatalf("Cannot evaluate the scenarios with kwctl: %+v", err)
+			}
+			t.Skipf("Skipping the kwctl executor: %+v", err)
+		})
+		return
+	}
+	e2e.Run(t, ".", kwctl, scenarios)
+
+	t.Run("kwctl/protocol_version", func(t *testing.T) {
+		version, err := kwctl.ProtocolVersion()
+		if err != nil {
+			t.Fatalf("Cannot get the protocol version: %+v", err)
+		}
+		if version != constants.ProtocolVersion {
+			t.Errorf("Got protocol version %q instead of %q", version, constants.ProtocolVersion)
+		}
+	})
+}
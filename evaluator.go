@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+
+	onelog "github.com/francoispqt/onelog"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+)
+
+// evaluator holds the dependencies the policy needs from the outside world
+// while evaluating requests and settings, together with the state of the
+// evaluation. A new evaluator is created for each invocation of the policy,
+// nothing is shared between invocations, hence they can run concurrently.
+// Tests build their own evaluator to get deterministic results.
+type evaluator struct {
+	// clock returns the current time. Inside of the WebAssembly runtime
+	// this is the clock provided by the host.
+	clock func() time.Time
+	// logger receives the logs of the evaluation.
+	logger *onelog.Logger
+	// host gives access to the capabilities of the Kubewarden host.
+	host HostClient
+	// resourceCache holds the Kubernetes resources looked up during the
+	// evaluation.
+	resourceCache map[string]resourceLookup
+	// exemptionCache holds the outcome of the access review exemption of
+	// each user, checked during the evaluation.
+	exemptionCache map[string]bool
+}
+
+// newLogger creates a logger sending its entries to the Kubewarden host. The
+// log writer buffers the entries, hence it must not be shared.
+func newLogger() *onelog.Logger {
+	return onelog.New(
+		&kubewarden.KubewardenLogWriter{},
+		onelog.ALL, // shortcut for onelog.DEBUG|onelog.INFO|onelog.WARN|onelog.ERROR|onelog.FATAL
+	)
+}
+
+// newEvaluator creates an evaluator that uses the real dependencies.
+func newEvaluator() *evaluator {
+	return &evaluator{
+		clock:          time.Now,
+		logger:         newLogger(),
+		host:           newHostClient(),
+		resourceCache:  map[string]resourceLookup{},
+		exemptionCache: map[string]bool{},
+	}
+}
+
+func validate(payload []byte) ([]byte, error) {
+	return newEvaluator().validate(payload)
+}
+
+func validateSettings(payload []byte) ([]byte, error) {
+	return newEvaluator().validateSettings(payload)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	concurrentInvocations = 16
+	// repeatedInvocations is the number of invocations made at the same time
+	// by each parallel test, to make sure they actually overlap even when
+	// the tests don't run in parallel, for example on a single CPU.
+	repeatedInvocations = 8
+)
+
+// TestConcurrentInvocations calls the waPC functions from parallel tests,
+// the race detector reports any state shared between the invocations:
+//
+//	go test -race -run TestConcurrentInvocations
+func TestConcurrentInvocations(t *testing.T) {
+	settings := Settings{DeniedNames: denyEntries("denied-0", "denied-1")}
+
+	for i := range concurrentInvocations {
+		name := fmt.Sprintf("allowed-%d", i)
+		if i%2 == 0 {
+			name = fmt.Sprintf("denied-%d", i%4/2)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			wg := sync.WaitGroup{}
+			for range repeatedInvocations {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					checkInvocation(t, &settings, name, i%2 == 0)
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// checkInvocation validates the settings and a Pod with the given name
+// through the waPC functions. It's called from many goroutines, hence it
+// reports the failures without stopping the test.
+func checkInvocation(t *testing.T, settings *Settings, name string, denied bool) {
+	t.Helper()
+
+	settingsPayload, err := validateSettings(rawObject(t, settings))
+	if err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+		return
+	}
+	settingsResponse := kubewarden_protocol.SettingsValidationResponse{}
+	if err = json.Unmarshal(settingsPayload, &settingsResponse); err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+		return
+	}
+	if !settingsResponse.Valid {
+		t.Errorf("Unexpected invalid settings: %v", settingsResponse.Message)
+		return
+	}
+
+	pod := corev1.Pod{Metadata: &metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	responsePayload, err := validate(newRequest(t, &pod).Settings(settings).Payload())
+	if err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+		return
+	}
+	response := kubewarden_protocol.ValidationResponse{}
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+		return
+	}
+
+	if response.Accepted == denied {
+		t.Errorf("Got accepted=%v instead of %v", response.Accepted, !denied)
+		return
+	}
+	if denied && *response.Message != fmt.Sprintf("The '%s' name is on the deny list", name) {
+		t.Errorf("Unexpected message: %s", *response.Message)
+	}
+}
+
+// TestConcurrentInvocationsDoNotShareLogs ensures the logs of an invocation
+// never end up in the ones of another invocation running at the same time.
+func TestConcurrentInvocationsDoNotShareLogs(t *testing.T) {
+	for i := range concurrentInvocations {
+		name := fmt.Sprintf("pod-%d", i)
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ev := newEvaluator()
+			logs := newLogSink(t)
+			ev.logger = logs.Logger()
+
+			pod := corev1.Pod{Metadata: &metav1.ObjectMeta{Name: name, Namespace: "default"}}
+			settings := Settings{DeniedNames: denyEntries(name)}
+			if response := newRequest(t, &pod).Settings(&settings).Validate(ev); response.Accepted {
+				t.Fatal("Unexpected approval")
+			}
+
+			for _, entry := range logs.Entries() {
+				if logged := entry.String("name"); logged != "" && logged != name {
+					t.Errorf("The logs of '%s' have been written by another invocation", logged)
+				}
+			}
+			logs.Find("info", "rejecting pod object")
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	onelog "github.com/francoispqt/onelog"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// AccessReviewExemption lets the users who are authorized to perform the
+// given action bypass the deny rules. The authorization is checked with a
+// SubjectAccessReview, through the Kubernetes capability of the Kubewarden
+// host.
+type AccessReviewExemption struct {
+	// Verb is a Kubernetes API verb, for example "escalate".
+	Verb string `json:"verb"`
+	// Group is the API group of the resource, for example
+	// "policies.kubewarden.io".
+	Group string `json:"group"`
+	// Resource is the resource type, for example "clusteradmissionpolicies".
+	// "*" means all the resources of the group.
+	Resource string `json:"resource"`
+	// Namespace of the action. Leave empty for cluster-wide resources.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Validate ensures the action is fully described.
+func (a *AccessReviewExemption) Validate() error {
+	if a.Verb == "" || a.Resource == "" {
+		return errors.New("access_review_exemption: verb and resource must be provided")
+	}
+
+	return nil
+}
+
+// isExempt returns true when the user who made the request is authorized to
+// bypass the deny rules.
+//
+// The outcome of the SubjectAccessReview is cached per user for the lifetime
+// of the evaluator, so that multiple rules can ask for it while making a
+// single host call.
+func (ev *evaluator) isExempt(settings *Settings, userInfo *kubewarden_protocol.UserInfo) bool {
+	exemption := settings.AccessReviewExemption
+	if exemption == nil || userInfo.Username == "" {
+		return false
+	}
+
+	cacheKey := userInfo.Username + "|" + strings.Join(userInfo.Groups, ",")
+	if exempt, found := ev.exemptionCache[cacheKey]; found {
+		return exempt
+	}
+
+	status, err := kubernetes.CanI(ev.capabilitiesHost(), kubernetes.CanIRequest{
+		SubjectAccessReview: kubernetes.SubjectAccessReview{
+			User:   userInfo.Username,
+			Groups: userInfo.Groups,
+			ResourceAttributes: kubernetes.ResourceAttributes{
+				Namespace: exemption.Namespace,
+				Verb:      exemption.Verb,
+				Group:     exemption.Group,
+				Resource:  exemption.Resource,
+			},
+		},
+	})
+	exempt := err == nil && status.Allowed
+	if err != nil {
+		ev.logger.WarnWithFields("cannot check access review exemption", func(e onelog.Entry) {
+			e.String("user", userInfo.Username)
+			e.String("error", err.Error())
+		})
+	}
+
+	if ev.exemptionCache == nil {
+		ev.exemptionCache = map[string]bool{}
+	}
+	ev.exemptionCache[cacheKey] = exempt
+
+	return exempt
+}
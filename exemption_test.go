@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const exemptPod = `{"metadata": {"name": "test-pod", "namespace": "shop"}}`
+
+func exemptionSettings() *Settings {
+	return &Settings{
+		DeniedNames:   denyEntries("test-pod"),
+		ContextAware:  true,
+		NameCollision: &NameCollisionSettings{},
+		AccessReviewExemption: &AccessReviewExemption{
+			Verb:     "escalate",
+			Group:    "policies.kubewarden.io",
+			Resource: "clusteradmissionpolicies",
+		},
+	}
+}
+
+func TestAccessReviewExemptionBypassesDenyRules(t *testing.T) {
+	host := newFakeHostClient()
+	host.Respond("kubernetes", "list_resources_all", []byte(podListResponse))
+	host.RespondJSON(t, "kubernetes", "can_i", kubernetes.SubjectAccessReviewStatus{Allowed: true})
+
+	response := newRequest(t, exemptPod).User("alice", "sre").Settings(exemptionSettings()).
+		Validate(newEvaluatorWithHost(host))
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+
+	// both the deny list and the name collision rules ask for the exemption,
+	// but the host is queried only once
+	calls := host.Calls("kubernetes", "can_i")
+	if len(calls) != 1 {
+		t.Fatalf("Expected a single access review, got %d", len(calls))
+	}
+	request := kubernetes.CanIRequest{}
+	if err := json.Unmarshal(calls[0].Payload, &request); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	review := request.SubjectAccessReview
+	if review.User != "alice" || len(review.Groups) != 1 || review.Groups[0] != "sre" ||
+		review.ResourceAttributes.Verb != "escalate" ||
+		review.ResourceAttributes.Group != "policies.kubewarden.io" ||
+		review.ResourceAttributes.Resource != "clusteradmissionpolicies" {
+		t.Errorf("Unexpected access review: %+v", review)
+	}
+}
+
+func TestAccessReviewExemptionDenied(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup func(host *fakeHostClient)
+	}{
+		{
+			name: "not allowed",
+			setup: func(host *fakeHostClient) {
+				host.RespondJSON(t, "kubernetes", "can_i", kubernetes.SubjectAccessReviewStatus{Allowed: false})
+			},
+		},
+		{
+			name: "lookup failure",
+			setup: func(host *fakeHostClient) {
+				host.Fail("kubernetes", "can_i", errors.New("boom"))
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := newFakeHostClient()
+			host.Respond("kubernetes", "list_resources_all", []byte(podListResponse))
+			tc.setup(host)
+
+			response := newRequest(t, exemptPod).User("bob").Settings(exemptionSettings()).
+				Validate(newEvaluatorWithHost(host))
+			if response.Accepted {
+				t.Fatalf("Unexpected acceptance")
+			}
+			expected := "The 'test-pod' name is on the deny list; " +
+				"The 'test-pod' name is already used by a Pod inside of the following namespaces: kube-system, search"
+			if *response.Message != expected {
+				t.Errorf("Got '%s' instead of '%s'", *response.Message, expected)
+			}
+			if calls := host.Calls("kubernetes", "can_i"); len(calls) != 1 {
+				t.Errorf("Expected a single access review, got %d", len(calls))
+			}
+		})
+	}
+}
+
+func TestAccessReviewExemptionCheckedOnlyOnViolations(t *testing.T) {
+	host := newFakeHostClient()
+	settings := exemptionSettings()
+	settings.DeniedNames = denyEntries("other-pod")
+	settings.NameCollision = nil
+
+	response := newRequest(t, exemptPod).User("alice").Settings(settings).Validate(newEvaluatorWithHost(host))
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+	if calls := host.Calls("kubernetes", "can_i"); len(calls) != 0 {
+		t.Errorf("Unexpected access reviews: %+v", calls)
+	}
+}
+
+func TestAccessReviewExemptionCachedPerUser(t *testing.T) {
+	host := newFakeHostClient()
+	host.Handle("kubernetes", "can_i", func(payload []byte) ([]byte, error) {
+		request := kubernetes.CanIRequest{}
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+		return json.Marshal(kubernetes.SubjectAccessReviewStatus{
+			Allowed: request.SubjectAccessReview.User == "alice",
+		})
+	})
+	ev := newEvaluatorWithHost(host)
+	settings := exemptionSettings()
+
+	alice := &kubewarden_protocol.UserInfo{Username: "alice"}
+	bob := &kubewarden_protocol.UserInfo{Username: "bob"}
+	for range 2 {
+		if !ev.isExempt(settings, alice) {
+			t.Errorf("Expected alice to be exempt")
+		}
+		if ev.isExempt(settings, bob) {
+			t.Errorf("Expected bob not to be exempt")
+		}
+	}
+
+	if calls := host.Calls("kubernetes", "can_i"); len(calls) != 2 {
+		t.Errorf("Expected one access review per user, got %d", len(calls))
+	}
+}
+
+func TestAccessReviewExemptionSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings Settings
+		expected string
+	}{
+		{
+			name: "requires context aware",
+			settings: Settings{
+				DeniedNames:           []DenyEntry{},
+				AccessReviewExemption: &AccessReviewExemption{Verb: "escalate", Resource: "*"},
+			},
+			expected: "access_review_exemption requires context_aware to be enabled",
+		},
+		{
+			name: "missing verb",
+			settings: Settings{
+				DeniedNames:           []DenyEntry{},
+				ContextAware:          true,
+				AccessReviewExemption: &AccessReviewExemption{Resource: "*"},
+			},
+			expected: "access_review_exemption: verb and resource must be provided",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.settings.Valid()
+			if valid || err == nil || err.Error() != tc.expected {
+				t.Errorf("Expected error '%s', got valid: %v, error: %v", tc.expected, valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+//go:generate go run ./hack/genfixtures -o test_data/fixtures
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	fixturesDir = "test_data/fixtures"
+	// fixtureImage is the image of the objects created or updated by the
+	// fixtures.
+	fixtureImage = "registry.example.com/nginx:1.27.1"
+)
+
+// fixture is an admission request of test_data/fixtures, written by
+// hack/genfixtures for each supported kind and operation.
+type fixture struct {
+	name    string
+	request kubewarden_protocol.KubernetesAdmissionRequest
+}
+
+func loadFixtures(t *testing.T) []fixture {
+	t.Helper()
+
+	entries, err := os.ReadDir(fixturesDir)
+	if err != nil {
+		t.Fatalf("Cannot list the fixtures: %+v", err)
+	}
+
+	fixtures := make([]fixture, 0, len(entries))
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(fixturesDir, entry.Name()))
+		if err != nil {
+			t.Fatalf("Cannot read %s: %+v", entry.Name(), err)
+		}
+		f := fixture{name: strings.TrimSuffix(entry.Name(), ".json")}
+		if err = json.Unmarshal(data, &f.request); err != nil {
+			t.Fatalf("Cannot decode %s: %+v", entry.Name(), err)
+		}
+		fixtures = append(fixtures, f)
+	}
+
+	return fixtures
+}
+
+// validate validates the request of the fixture with the given settings.
+func (f *fixture) validate(t *testing.T, ev *evaluator, settings *Settings) kubewarden_protocol.ValidationResponse {
+	t.Helper()
+
+	payload, err := json.Marshal(&kubewarden_protocol.ValidationRequest{
+		Request:  f.request,
+		Settings: rawObject(t, settings),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	responsePayload, err := ev.validate(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	response := kubewarden_protocol.ValidationResponse{}
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	return response
+}
+
+func TestFixturesCoverAllKindsAndOperations(t *testing.T) {
+	found := map[string]bool{}
+	for _, f := range loadFixtures(t) {
+		found[f.request.Kind.Kind+"/"+f.request.Operation] = true
+	}
+
+	kinds := []string{
+		"Pod", "Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob", "ReplicaSet", "ReplicationController",
+	}
+	for _, kind := range kinds {
+		for _, operation := range []string{operationCreate, operationUpdate, operationDelete} {
+			if !found[kind+"/"+operation] {
+				t.Errorf("No fixture for %s of %s, run `go generate`", operation, kind)
+			}
+		}
+	}
+}
+
+func TestFixturesDeniedName(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings Settings
+		// rejected is whether the request is rejected, by operation. The
+		// deny list applies only to the objects being created.
+		rejected map[string]bool
+	}{
+		{
+			name:     "allowed name",
+			settings: Settings{DeniedNames: denyEntries("foo")},
+			rejected: map[string]bool{},
+		},
+		{
+			name:     "denied name",
+			settings: Settings{DeniedNames: denyEntries("nginx")},
+			rejected: map[string]bool{operationCreate: true},
+		},
+	}
+
+	fixtures := loadFixtures(t)
+	for _, tc := range cases {
+		for _, f := range fixtures {
+			t.Run(tc.name+"/"+f.name, func(t *testing.T) {
+				response := f.validate(t, newEvaluator(), &tc.settings)
+				rejected := tc.rejected[f.request.Operation]
+				if response.Accepted == rejected {
+					t.Fatalf("Got accepted=%v instead of %v", response.Accepted, !rejected)
+				}
+				if rejected && *response.Message != "The 'nginx' name is on the deny list" {
+					t.Errorf("Unexpected message: %s", *response.Message)
+				}
+			})
+		}
+	}
+}
+
+func TestFixturesPodSpec(t *testing.T) {
+	for _, f := range loadFixtures(t) {
+		if f.request.Operation == operationDelete {
+			continue
+		}
+
+		t.Run(f.name, func(t *testing.T) {
+			validationRequest := kubewarden_protocol.ValidationRequest{Request: f.request}
+			pod, err := decodePod(&f.request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %+v", err)
+			}
+			spec, err := podSpec(&validationRequest, pod)
+			if err != nil {
+				t.Fatalf("Unexpected error: %+v", err)
+			}
+			if spec == nil || len(spec.Containers) != 1 || spec.Containers[0].Image != fixtureImage {
+				t.Errorf("Unexpected Pod spec %+v", spec)
+			}
+		})
+	}
+}
+
+func TestFixturesDigestPinning(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("1", 64)
+	settings := Settings{DeniedNames: []DenyEntry{}, DigestPinning: &DigestPinningSettings{}}
+
+	for _, f := range loadFixtures(t) {
+		t.Run(f.name, func(t *testing.T) {
+			host := newFakeHostClient()
+			resolveDigests(host, map[string]string{fixtureImage: digest})
+
+			response := f.validate(t, newEvaluatorWithHost(host), &settings)
+			if f.request.Operation != operationCreate {
+				if !response.Accepted || response.MutatedObject != nil {
+					t.Errorf("Unexpected response to the %s: %+v", f.request.Operation, response)
+				}
+				return
+			}
+
+			mutated := map[string]interface{}{}
+			decodeMutatedObject(t, &response, &mutated)
+			raw, _ := json.Marshal(mutated)
+			if !strings.Contains(string(raw), fixtureImage+"@"+digest) {
+				t.Errorf("The image has not been pinned: %s", raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	// TinyGo policies cannot read the time zone database of the host, embed
+	// it to be able to evaluate weekly windows in named time zones.
+	_ "time/tzdata"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	daysPerWeek        = 7
+	maxWeeklyDuration  = daysPerWeek * 24 * time.Hour
+	weeklyWindowLayout = "15:04"
+)
+
+// FreezeSettings describes the change-freeze windows. During a window all the
+// CREATE, UPDATE and DELETE operations on the matched kinds are rejected,
+// unless the requester is exempt.
+type FreezeSettings struct {
+	// Kinds the windows apply to, for example "Deployment". The Pods and all
+	// the controllers of Pods are frozen when left empty, including the Pods and the ReplicaSets
+	// changed by the controllers, whose service accounts must then be
+	// exempted.
+	Kinds []string `json:"kinds,omitempty"`
+	// Users that can perform changes during a freeze.
+	ExemptUsers []string `json:"exempt_users,omitempty"`
+	// Groups whose members can perform changes during a freeze.
+	ExemptGroups []string         `json:"exempt_groups,omitempty"`
+	Weekly       []WeeklyWindow   `json:"weekly,omitempty"`
+	Absolute     []AbsoluteWindow `json:"absolute,omitempty"`
+}
+
+// WeeklyWindow is a window recurring every week. It opens on each one of
+// the given days at the given time, evaluated inside of the given time zone,
+// and stays open for the given duration. A window can span multiple days,
+// for example opening on Friday at 18:00 for 62h freezes the whole weekend.
+type WeeklyWindow struct {
+	Name string `json:"name"`
+	// Days of the week, for example "Mon" or "Monday".
+	Days []string `json:"days"`
+	// Start is the opening time, in the "HH:MM" 24-hour format.
+	Start string `json:"start"`
+	// Duration of the window, for example "14h" or "90m".
+	Duration string `json:"duration"`
+	// Timezone is an IANA time zone name, for example "Europe/Berlin".
+	// UTC is used when left empty.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// AbsoluteWindow is a one-off window defined by two RFC 3339 timestamps.
+type AbsoluteWindow struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// weeklySchedule is the parsed version of a WeeklyWindow.
+type weeklySchedule struct {
+	days     [daysPerWeek]bool
+	hour     int
+	minute   int
+	duration time.Duration
+	location *time.Location
+}
+
+// ActiveFreeze describes the freeze window that is currently open.
+type ActiveFreeze struct {
+	Name string
+	End  time.Time
+}
+
+// Validate ensures all the windows can be evaluated.
+func (f *FreezeSettings) Validate() error {
+	for i := range f.Weekly {
+		if _, err := f.Weekly[i].schedule(); err != nil {
+			return fmt.Errorf("freeze_windows.weekly[%d]: %w", i, err)
+		}
+	}
+	for i, window := range f.Absolute {
+		if !window.Start.Before(window.End) {
+			return fmt.Errorf("freeze_windows.absolute[%d]: 'start' must be before 'end'", i)
+		}
+	}
+
+	return nil
+}
+
+// AppliesTo returns true when the freeze covers the given kind.
+func (f *FreezeSettings) AppliesTo(kind string) bool {
+	if len(f.Kinds) == 0 {
+		return true
+	}
+	for _, k := range f.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsExempt returns true when the user can perform changes during a freeze.
+func (f *FreezeSettings) IsExempt(userInfo *kubewarden_protocol.UserInfo) bool {
+	for _, user := range f.ExemptUsers {
+		if user == userInfo.Username {
+			return true
+		}
+	}
+	for _, exemptGroup := range f.ExemptGroups {
+		for _, group := range userInfo.Groups {
+			if exemptGroup == group {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ActiveWindow returns the window that is open at the given time, or nil when
+// there is none.
+func (f *FreezeSettings) ActiveWindow(now time.Time) (*ActiveFreeze, error) {
+	for _, window := range f.Absolute {
+		if !now.Before(window.Start) && now.Before(window.End) {
+			return &ActiveFreeze{Name: window.Name, End: window.End}, nil
+		}
+	}
+
+	for i := range f.Weekly {
+		schedule, err := f.Weekly[i].schedule()
+		if err != nil {
+			return nil, err
+		}
+		if end, open := schedule.openAt(now); open {
+			return &ActiveFreeze{Name: f.Weekly[i].Name, End: end}, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil // no window being open is not an error
+}
+
+func (w *WeeklyWindow) schedule() (weeklySchedule, error) {
+	schedule := weeklySchedule{}
+
+	if len(w.Days) == 0 {
+		return schedule, errors.New("at least one day must be provided")
+	}
+	for _, day := range w.Days {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return schedule, err
+		}
+		schedule.days[weekday] = true
+	}
+
+	start, err := time.Parse(weeklyWindowLayout, w.Start)
+	if err != nil {
+		return schedule, fmt.Errorf("'start' must use the HH:MM format: %w", err)
+	}
+	schedule.hour, schedule.minute = start.Hour(), start.Minute()
+
+	schedule.duration, err = time.ParseDuration(w.Duration)
+	if err != nil {
+		return schedule, fmt.Errorf("invalid 'duration': %w", err)
+	}
+	if schedule.duration <= 0 || schedule.duration > maxWeeklyDuration {
+		return schedule, fmt.Errorf("'duration' must be positive and not longer than %s", maxWeeklyDuration)
+	}
+
+	schedule.location, err = time.LoadLocation(w.Timezone)
+	if err != nil {
+		return schedule, fmt.Errorf("unknown 'timezone' %q: %w", w.Timezone, err)
+	}
+
+	return schedule, nil
+}
+
+// openAt returns the end of the window and true when the window is open at
+// the given time. The window may have been opened up to a week before.
+func (s *weeklySchedule) openAt(now time.Time) (time.Time, bool) {
+	local := now.In(s.location)
+	for daysBack := 0; daysBack <= daysPerWeek; daysBack++ {
+		day := local.AddDate(0, 0, -daysBack)
+		if !s.days[day.Weekday()] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), s.hour, s.minute, 0, 0, s.location)
+		end := start.Add(s.duration)
+		if !local.Before(start) && local.Before(end) {
+			return end, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func parseWeekday(day string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := weekday.String()
+		if strings.EqualFold(day, name) || strings.EqualFold(day, name[:3]) {
+			return weekday, nil
+		}
+	}
+
+	return time.Sunday, fmt.Errorf("unknown day of the week %q", day)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+func TestWeeklyWindowSpanningTheWeekend(t *testing.T) {
+	freeze := FreezeSettings{
+		Weekly: []WeeklyWindow{
+			{Name: "weekend", Days: []string{"Fri"}, Start: "18:00", Duration: "62h", Timezone: "Europe/Berlin"},
+		},
+	}
+	if err := freeze.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	cases := []struct {
+		now  time.Time
+		open bool
+	}{
+		// Friday 2026-10-16
+		{time.Date(2026, 10, 16, 17, 59, 0, 0, berlin), false},
+		{time.Date(2026, 10, 16, 18, 0, 0, 0, berlin), true},
+		// same instant, expressed in UTC
+		{time.Date(2026, 10, 16, 16, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 10, 18, 12, 0, 0, 0, berlin), true},
+		{time.Date(2026, 10, 19, 7, 59, 0, 0, berlin), true},
+		{time.Date(2026, 10, 19, 8, 0, 0, 0, berlin), false},
+		{time.Date(2026, 10, 21, 12, 0, 0, 0, berlin), false},
+	}
+
+	for _, tc := range cases {
+		active, err := freeze.ActiveWindow(tc.now)
+		if err != nil {
+			t.Fatalf("Unexpected error: %+v", err)
+		}
+		if (active != nil) != tc.open {
+			t.Errorf("at %s: got open=%v instead of %v", tc.now, active != nil, tc.open)
+		}
+	}
+}
+
+func TestWeeklyWindowAcrossDaylightSavingTimeChange(t *testing.T) {
+	// Clocks move back from 03:00 to 02:00 in Berlin on Sunday 2026-10-25
+	freeze := FreezeSettings{
+		Weekly: []WeeklyWindow{
+			{Name: "sunday", Days: []string{"sunday"}, Start: "00:00", Duration: "10h", Timezone: "Europe/Berlin"},
+		},
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	active, err := freeze.ActiveWindow(time.Date(2026, 10, 25, 8, 30, 0, 0, berlin))
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if active == nil {
+		t.Fatalf("Expected the window to be open")
+	}
+
+	// the window lasts 10 real hours, which end at 09:00 local time
+	expectedEnd := time.Date(2026, 10, 25, 9, 0, 0, 0, berlin)
+	if !active.End.Equal(expectedEnd) {
+		t.Errorf("Got end %s instead of %s", active.End, expectedEnd)
+	}
+}
+
+func TestAbsoluteWindow(t *testing.T) {
+	start := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2027, 1, 6, 0, 0, 0, 0, time.UTC)
+	freeze := FreezeSettings{
+		Absolute: []AbsoluteWindow{{Name: "holidays", Start: start, End: end}},
+	}
+
+	active, err := freeze.ActiveWindow(start)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if active == nil || active.Name != "holidays" || !active.End.Equal(end) {
+		t.Errorf("Unexpected active window: %+v", active)
+	}
+
+	active, err = freeze.ActiveWindow(end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if active != nil {
+		t.Errorf("The window must be closed at its end")
+	}
+}
+
+func TestInvalidFreezeWindows(t *testing.T) {
+	cases := map[string]FreezeSettings{
+		"no days": {
+			Weekly: []WeeklyWindow{{Start: "18:00", Duration: "1h"}},
+		},
+		"unknown day": {
+			Weekly: []WeeklyWindow{{Days: []string{"Caturday"}, Start: "18:00", Duration: "1h"}},
+		},
+		"bad start": {
+			Weekly: []WeeklyWindow{{Days: []string{"Mon"}, Start: "6pm", Duration: "1h"}},
+		},
+		"duration too long": {
+			Weekly: []WeeklyWindow{{Days: []string{"Mon"}, Start: "18:00", Duration: "169h"}},
+		},
+		"unknown timezone": {
+			Weekly: []WeeklyWindow{{Days: []string{"Mon"}, Start: "18:00", Duration: "1h", Timezone: "Mars/Olympus"}},
+		},
+		"inverted absolute window": {
+			Absolute: []AbsoluteWindow{{Start: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+	}
+
+	for name, freeze := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := freeze.Validate(); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestFreezeAppliesToAndExemptions(t *testing.T) {
+	freeze := FreezeSettings{
+		Kinds:        []string{"Pod"},
+		ExemptUsers:  []string{"release-bot"},
+		ExemptGroups: []string{"sre"},
+	}
+
+	if !freeze.AppliesTo("Pod") || freeze.AppliesTo("Secret") {
+		t.Errorf("Unexpected kind matching")
+	}
+	if !(&FreezeSettings{}).AppliesTo("Secret") {
+		t.Errorf("A freeze without kinds must apply to all of them")
+	}
+
+	if !freeze.IsExempt(&kubewarden_protocol.UserInfo{Username: "release-bot"}) {
+		t.Errorf("User should be exempt")
+	}
+	if !freeze.IsExempt(&kubewarden_protocol.UserInfo{Username: "alice", Groups: []string{"dev", "sre"}}) {
+		t.Errorf("Group member should be exempt")
+	}
+	if freeze.IsExempt(&kubewarden_protocol.UserInfo{Username: "alice", Groups: []string{"dev"}}) {
+		t.Errorf("User should not be exempt")
+	}
+}
+
+func TestRejectionDuringChangeFreeze(t *testing.T) {
+	start := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2027, 1, 6, 0, 0, 0, 0, time.UTC)
+	settings := Settings{
+		FreezeWindows: &FreezeSettings{
+			Kinds:       []string{"Pod"},
+			ExemptUsers: []string{"release-bot"},
+			Absolute:    []AbsoluteWindow{{Name: "holidays", Start: start, End: end}},
+		},
+	}
+	pod := testPod("test-pod", "default")
+
+	cases := []struct {
+		operation string
+		user      string
+		now       time.Time
+		accepted  bool
+	}{
+		{"CREATE", "alice", start.Add(time.Hour), false},
+		{"UPDATE", "alice", start.Add(time.Hour), false},
+		{"DELETE", "alice", start.Add(time.Hour), false},
+		{"DELETE", "release-bot", start.Add(time.Hour), true},
+		{"CREATE", "alice", end.Add(time.Hour), true},
+		{"DELETE", "alice", end.Add(time.Hour), true},
+	}
+
+	for _, tc := range cases {
+		request := newRequest(t, pod)
+		switch tc.operation {
+		case operationUpdate:
+			request = updateRequest(t, pod, pod)
+		case operationDelete:
+			request = deleteRequest(t, pod)
+		}
+
+		ev := newEvaluator()
+		ev.clock = fixedClock(tc.now)
+		response := request.User(tc.user).Settings(&settings).Validate(ev)
+
+		if response.Accepted != tc.accepted {
+			t.Errorf("%s by %s at %s: got accepted=%v instead of %v",
+				tc.operation, tc.user, tc.now, response.Accepted, tc.accepted)
+		}
+		if !response.Accepted {
+			expectedMessage := "Pod objects cannot be changed during the 'holidays' change freeze, which ends at 2027-01-06T00:00:00Z"
+			if *response.Message != expectedMessage {
+				t.Errorf("Got '%s' instead of '%s'", *response.Message, expectedMessage)
+			}
+		}
+	}
+}
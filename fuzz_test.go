@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	minRejectionCode = 400
+	maxRejectionCode = 599
+)
+
+// fuzzSeeds returns the admission requests and the settings found inside of
+// test_data, used to seed the corpora.
+func fuzzSeeds(f *testing.F) ([][]byte, [][]byte) {
+	f.Helper()
+
+	requests := [][]byte{}
+	settings := [][]byte{[]byte(`{"denied_names": ["test-pod"]}`)}
+
+	pod, err := os.ReadFile("test_data/pod.json")
+	if err != nil {
+		f.Fatalf("Unexpected error: %+v", err)
+	}
+	requests = append(requests, pod)
+
+	cases, err := filepath.Glob(filepath.Join(casesDir, "*"))
+	if err != nil {
+		f.Fatalf("Unexpected error: %+v", err)
+	}
+	for _, dir := range cases {
+		if request, err := os.ReadFile(filepath.Join(dir, "request.json")); err == nil {
+			requests = append(requests, request)
+		}
+		if caseSettings, err := os.ReadFile(filepath.Join(dir, "settings.json")); err == nil {
+			settings = append(settings, caseSettings)
+		}
+	}
+
+	return requests, settings
+}
+
+// checkValidationResponse ensures the response of `validate` is well-formed.
+func checkValidationResponse(t *testing.T, responsePayload []byte, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("validate returned an error: %+v", err)
+	}
+	response := kubewarden_protocol.ValidationResponse{}
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		t.Fatalf("Malformed response %q: %+v", responsePayload, err)
+	}
+
+	if response.Accepted {
+		return
+	}
+	if response.Message == nil || *response.Message == "" {
+		t.Errorf("Rejection without a message: %s", responsePayload)
+	}
+	if response.Code != nil && (*response.Code < minRejectionCode || *response.Code > maxRejectionCode) {
+		t.Errorf("Rejection with code %d outside of the allowed range: %s", *response.Code, responsePayload)
+	}
+	if response.MutatedObject != nil {
+		t.Errorf("Rejection with a mutated object: %s", responsePayload)
+	}
+}
+
+func FuzzValidate(f *testing.F) {
+	requests, settings := fuzzSeeds(f)
+	for _, request := range requests {
+		for _, s := range settings {
+			payload, err := json.Marshal(map[string]json.RawMessage{"request": request, "settings": s})
+			if err != nil {
+				f.Fatalf("Unexpected error: %+v", err)
+			}
+			f.Add(payload)
+		}
+	}
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`not json`))
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		responsePayload, err := newEvaluator().validate(payload)
+		checkValidationResponse(t, responsePayload, err)
+	})
+}
+
+// FuzzValidateRequest mutates the structure of valid requests: the
+// operation, the kind, the object and the settings.
+func FuzzValidateRequest(f *testing.F) {
+	requests, settings := fuzzSeeds(f)
+	for _, raw := range requests {
+		request := kubewarden_protocol.KubernetesAdmissionRequest{}
+		if err := json.Unmarshal(raw, &request); err != nil {
+			f.Fatalf("Unexpected error: %+v", err)
+		}
+		object := request.Object
+		if request.Operation == operationDelete {
+			object = request.OldObject
+		}
+		for _, s := range settings {
+			f.Add(request.Operation, request.Kind.Group, request.Kind.Kind, request.UserInfo.Username, []byte(object), s)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, operation, group, kind, username string, object, settings []byte) {
+		request := kubewarden_protocol.KubernetesAdmissionRequest{
+			Kind:      kubewarden_protocol.GroupVersionKind{Group: group, Version: "v1", Kind: kind},
+			Operation: operation,
+			UserInfo:  kubewarden_protocol.UserInfo{Username: username},
+		}
+		if json.Valid(object) {
+			request.Object = object
+			request.OldObject = object
+		}
+		if !json.Valid(settings) {
+			settings = []byte(`{}`)
+		}
+		payload, err := json.Marshal(&kubewarden_protocol.ValidationRequest{Request: request, Settings: settings})
+		if err != nil {
+			t.Skip()
+		}
+
+		responsePayload, err := newEvaluator().validate(payload)
+		checkValidationResponse(t, responsePayload, err)
+	})
+}
+
+func FuzzValidateSettings(f *testing.F) {
+	_, settings := fuzzSeeds(f)
+	for _, s := range settings {
+		f.Add(s)
+	}
+	f.Add([]byte(`null`))
+	f.Add([]byte(`{"denied_names": [{"name": "a", "not_before": "2030-01-01T00:00:00Z", "expires_at": "2020-01-01T00:00:00Z"}]}`))
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		responsePayload, err := newEvaluator().validateSettings(payload)
+		if err != nil {
+			t.Fatalf("validateSettings returned an error: %+v", err)
+		}
+
+		response := kubewarden_protocol.SettingsValidationResponse{}
+		if err = json.Unmarshal(responsePayload, &response); err != nil {
+			t.Fatalf("Malformed response %q: %+v", responsePayload, err)
+		}
+		if !response.Valid && (response.Message == nil || *response.Message == "") {
+			t.Errorf("Invalid settings without a message: %s", responsePayload)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const goldenDir = "test_data/golden"
+
+//nolint:gochecknoglobals // flags of the tests must be registered before they are parsed
+var updateGolden = flag.Bool("update", false, "update the golden files inside of "+goldenDir)
+
+// assertGolden compares the JSON payload emitted by the policy with the
+// golden file at test_data/golden/<name>. The payload is indented before
+// being compared, so that any difference is easy to spot during review.
+//
+// The golden files are written, instead of being compared, when the tests
+// are run with the `-update` flag:
+//
+//	go test -run TestCases -update
+func assertGolden(t *testing.T, name string, payload []byte) {
+	t.Helper()
+
+	indented := bytes.Buffer{}
+	if err := json.Indent(&indented, payload, "", "  "); err != nil {
+		t.Fatalf("Cannot indent the payload: %+v", err)
+	}
+	indented.WriteByte('\n')
+
+	path := filepath.Join(goldenDir, name)
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Cannot create the golden directory: %+v", err)
+		}
+		if err := os.WriteFile(path, indented.Bytes(), 0o644); err != nil {
+			t.Fatalf("Cannot write %s: %+v", path, err)
+		}
+		return
+	}
+
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read %s, run `go test -run %s -update` to create it: %+v", path, t.Name(), err)
+	}
+	if !bytes.Equal(golden, indented.Bytes()) {
+		t.Errorf("The response does not match %s, run `go test -run %s -update` if the change is expected.\n"+
+			"Expected:\n%s\nGot:\n%s", path, t.Name(), golden, indented.Bytes())
+	}
+}
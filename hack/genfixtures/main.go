@@ -0,0 +1,341 @@
+// genfixtures writes the admission request fixtures of test_data/fixtures:
+// one request for each kind supported by the policy and for each operation.
+//
+// The output is deterministic, so that running the generator again leaves
+// the tree untouched unless the generator itself changes. It's run through
+// `go generate` from the root of the repository.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	appsv1 "github.com/kubewarden/k8s-objects/api/apps/v1"
+	batchv1 "github.com/kubewarden/k8s-objects/api/batch/v1"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	"github.com/kubewarden/k8s-objects/apimachinery/pkg/api/resource"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	objectName      = "nginx"
+	objectNamespace = "default"
+	containerPort   = 80
+	replicas        = 3
+
+	// storedGeneration is the generation of the objects stored inside of the
+	// cluster, updatedGeneration the one they have once updated.
+	storedGeneration  = 1
+	updatedGeneration = 2
+	// resourceVersions is the base of the resource versions of the objects.
+	resourceVersions = 1000
+
+	// oldImage is the image of the objects stored inside of the cluster,
+	// newImage is the one of the objects being created or updated to.
+	oldImage = "registry.example.com/nginx:1.27.0"
+	newImage = "registry.example.com/nginx:1.27.1"
+
+	operationCreate = "CREATE"
+	operationUpdate = "UPDATE"
+	operationDelete = "DELETE"
+)
+
+// groupVersionKind matches the GroupVersionKind of the admission requests.
+type groupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// groupVersionResource matches the GroupVersionResource of the admission
+// requests.
+type groupVersionResource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+type userInfo struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+}
+
+type operationOptions struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// admissionRequest is an admission request, in the format accepted by
+// `kwctl run -r`.
+type admissionRequest struct {
+	UID             string               `json:"uid"`
+	Kind            groupVersionKind     `json:"kind"`
+	Resource        groupVersionResource `json:"resource"`
+	RequestKind     groupVersionKind     `json:"requestKind"`
+	RequestResource groupVersionResource `json:"requestResource"`
+	Name            string               `json:"name"`
+	Namespace       string               `json:"namespace"`
+	Operation       string               `json:"operation"`
+	UserInfo        userInfo             `json:"userInfo"`
+	Object          interface{}          `json:"object"`
+	OldObject       interface{}          `json:"oldObject"`
+	DryRun          bool                 `json:"dryRun"`
+	Options         operationOptions     `json:"options"`
+}
+
+// kind is a kind supported by the policy, together with the function
+// building an object of that kind around the given Pod template.
+type kind struct {
+	gvk      groupVersionKind
+	resource string
+	object   func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{}
+}
+
+func kinds() []kind {
+	return []kind{
+		{
+			gvk:      groupVersionKind{Version: "v1", Kind: "Pod"},
+			resource: "pods",
+			object: func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{} {
+				return &corev1.Pod{APIVersion: "v1", Kind: "Pod", Metadata: metadata, Spec: template.Spec}
+			},
+		},
+		{
+			gvk:      groupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			resource: "deployments",
+			object: func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{} {
+				return &appsv1.Deployment{
+					APIVersion: "apps/v1", Kind: "Deployment", Metadata: metadata,
+					Spec: &appsv1.DeploymentSpec{Replicas: replicas, Selector: selector(), Template: template},
+				}
+			},
+		},
+		{
+			gvk:      groupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+			resource: "statefulsets",
+			object: func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{} {
+				serviceName := objectName
+				return &appsv1.StatefulSet{
+					APIVersion: "apps/v1", Kind: "StatefulSet", Metadata: metadata,
+					Spec: &appsv1.StatefulSetSpec{
+						Replicas: replicas, Selector: selector(), ServiceName: &serviceName, Template: template,
+					},
+				}
+			},
+		},
+		{
+			gvk:      groupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+			resource: "daemonsets",
+			object: func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{} {
+				return &appsv1.DaemonSet{
+					APIVersion: "apps/v1", Kind: "DaemonSet", Metadata: metadata,
+					Spec: &appsv1.DaemonSetSpec{Selector: selector(), Template: template},
+				}
+			},
+		},
+		{
+			gvk:      groupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+			resource: "replicasets",
+			object: func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{} {
+				return &appsv1.ReplicaSet{
+					APIVersion: "apps/v1", Kind: "ReplicaSet", Metadata: metadata,
+					Spec: &appsv1.ReplicaSetSpec{Replicas: replicas, Selector: selector(), Template: template},
+				}
+			},
+		},
+		{
+			gvk:      groupVersionKind{Version: "v1", Kind: "ReplicationController"},
+			resource: "replicationcontrollers",
+			object: func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{} {
+				return &corev1.ReplicationController{
+					APIVersion: "v1", Kind: "ReplicationController", Metadata: metadata,
+					Spec: &corev1.ReplicationControllerSpec{
+						Replicas: replicas, Selector: labels(), Template: template,
+					},
+				}
+			},
+		},
+		{
+			gvk:      groupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+			resource: "jobs",
+			object: func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{} {
+				template.Spec.RestartPolicy = "OnFailure"
+				return &batchv1.Job{
+					APIVersion: "batch/v1", Kind: "Job", Metadata: metadata,
+					Spec: &batchv1.JobSpec{Template: template},
+				}
+			},
+		},
+		{
+			gvk:      groupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+			resource: "cronjobs",
+			object: func(metadata *metav1.ObjectMeta, template *corev1.PodTemplateSpec) interface{} {
+				template.Spec.RestartPolicy = "OnFailure"
+				schedule := "*/5 * * * *"
+				return &batchv1.CronJob{
+					APIVersion: "batch/v1", Kind: "CronJob", Metadata: metadata,
+					Spec: &batchv1.CronJobSpec{
+						Schedule:    &schedule,
+						JobTemplate: &batchv1.JobTemplateSpec{Spec: &batchv1.JobSpec{Template: template}},
+					},
+				}
+			},
+		},
+	}
+}
+
+func labels() map[string]string {
+	return map[string]string{"app": objectName}
+}
+
+func selector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: labels()}
+}
+
+// uid returns a UID derived from the given values, so that the fixtures
+// are the same at each run.
+func uid(values ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(values, "/")))
+	hex := fmt.Sprintf("%x", sum[:16])
+
+	return strings.Join([]string{hex[0:8], hex[8:12], hex[12:16], hex[16:20], hex[20:32]}, "-")
+}
+
+// podTemplate returns the template of the Pods running the given image.
+func podTemplate(image string) *corev1.PodTemplateSpec {
+	containerName := objectName
+	port := int32(containerPort)
+
+	return &corev1.PodTemplateSpec{
+		Metadata: &metav1.ObjectMeta{Labels: labels()},
+		Spec: &corev1.PodSpec{
+			Containers: []*corev1.Container{
+				{
+					Name:  &containerName,
+					Image: image,
+					Ports: []*corev1.ContainerPort{{ContainerPort: &port, Protocol: "TCP"}},
+					Resources: &corev1.ResourceRequirements{
+						Requests: map[string]*resource.Quantity{"cpu": quantity("100m"), "memory": quantity("128Mi")},
+						Limits:   map[string]*resource.Quantity{"memory": quantity("256Mi")},
+					},
+					SecurityContext: &corev1.SecurityContext{RunAsNonRoot: true},
+				},
+			},
+			RestartPolicy: "Always",
+		},
+	}
+}
+
+func quantity(value string) *resource.Quantity {
+	q := resource.Quantity(value)
+	return &q
+}
+
+// object returns an object of the given kind, running the given image. The
+// objects stored inside of the cluster, which have a generation, also have
+// the fields set by the API server.
+func object(k *kind, image string, generation int64) interface{} {
+	metadata := &metav1.ObjectMeta{
+		Name:      objectName,
+		Namespace: objectNamespace,
+		Labels:    labels(),
+	}
+	if generation > 0 {
+		creationTimestamp := metav1.Time(strfmt.DateTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+		metadata.UID = uid(k.gvk.Kind, objectName)
+		metadata.CreationTimestamp = &creationTimestamp
+		metadata.Generation = generation
+		metadata.ResourceVersion = strconv.FormatInt(resourceVersions+generation, 10)
+	}
+
+	return k.object(metadata, podTemplate(image))
+}
+
+// request returns the admission request of the given operation on an
+// object of the given kind.
+func request(k *kind, operation string) admissionRequest {
+	resource := groupVersionResource{Group: k.gvk.Group, Version: k.gvk.Version, Resource: k.resource}
+	req := admissionRequest{
+		UID:             uid(k.gvk.Kind, operation),
+		Kind:            k.gvk,
+		Resource:        resource,
+		RequestKind:     k.gvk,
+		RequestResource: resource,
+		Name:            objectName,
+		Namespace:       objectNamespace,
+		Operation:       operation,
+		UserInfo: userInfo{
+			Username: "kubernetes-admin",
+			Groups:   []string{"system:masters", "system:authenticated"},
+		},
+		Options: operationOptions{APIVersion: "meta.k8s.io/v1"},
+	}
+
+	switch operation {
+	case operationCreate:
+		req.Object = object(k, newImage, 0)
+		req.Options.Kind = "CreateOptions"
+	case operationUpdate:
+		req.Object = object(k, newImage, updatedGeneration)
+		req.OldObject = object(k, oldImage, storedGeneration)
+		req.Options.Kind = "UpdateOptions"
+	case operationDelete:
+		req.OldObject = object(k, oldImage, storedGeneration)
+		req.Options.Kind = "DeleteOptions"
+	}
+
+	return req
+}
+
+// fixtures returns the content of each fixture, keyed by file name.
+func fixtures() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, k := range kinds() {
+		for _, operation := range []string{operationCreate, operationUpdate, operationDelete} {
+			data, err := json.MarshalIndent(request(&k, operation), "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("cannot serialize the %s %s request: %w", operation, k.gvk.Kind, err)
+			}
+			name := fmt.Sprintf("%s-%s.json", strings.ToLower(k.gvk.Kind), strings.ToLower(operation))
+			files[name] = append(data, '\n')
+		}
+	}
+
+	return files, nil
+}
+
+// generate writes all the fixtures inside of the given directory.
+func generate(dir string) error {
+	files, err := fixtures()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	for name, data := range files {
+		if err = os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil { //nolint:gosec // fixtures are not secret
+			return fmt.Errorf("cannot write %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	dir := flag.String("o", "test_data/fixtures", "directory where the fixtures are written")
+	flag.Parse()
+
+	if err := generate(*dir); err != nil {
+		log.Fatal(err)
+	}
+}
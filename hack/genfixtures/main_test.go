@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const fixturesDir = "../../test_data/fixtures"
+
+func TestFixturesAreUpToDate(t *testing.T) {
+	files, err := fixtures()
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	for name, expected := range files {
+		actual, err := os.ReadFile(filepath.Join(fixturesDir, name))
+		if err != nil {
+			t.Errorf("Cannot read %s, run `go generate` to create it: %+v", name, err)
+			continue
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("%s is outdated, run `go generate` to update it", name)
+		}
+	}
+
+	entries, err := os.ReadDir(fixturesDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	for _, entry := range entries {
+		if _, found := files[entry.Name()]; !found {
+			t.Errorf("%s is not written by the generator", entry.Name())
+		}
+	}
+}
+
+func TestFixturesAreDeterministic(t *testing.T) {
+	first, err := fixtures()
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	second, err := fixtures()
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	for name, data := range first {
+		if !bytes.Equal(data, second[name]) {
+			t.Errorf("%s changes between runs", name)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities"
+)
+
+// HostClient performs the calls to the capabilities offered by the
+// Kubewarden host.
+//
+// The interface matches the one expected by the capabilities of the
+// Kubewarden SDK, so that the SDK helpers can be used with any HostClient.
+// Tests use a fake implementation to control what the host returns.
+type HostClient interface {
+	HostCall(binding, namespace, operation string, payload []byte) ([]byte, error)
+}
+
+// capabilitiesHost wraps the host client of the evaluator into the type
+// expected by the capabilities of the Kubewarden SDK.
+func (ev *evaluator) capabilitiesHost() *capabilities.Host {
+	return &capabilities.Host{Client: ev.host}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+)
+
+// hostCall records a call made to the fake host.
+type hostCall struct {
+	Binding   string
+	Namespace string
+	Operation string
+	Payload   []byte
+}
+
+// hostHandler computes the response of the fake host to a call.
+type hostHandler func(payload []byte) ([]byte, error)
+
+// fakeHostClient is a programmable HostClient. The responses are configured
+// per capability namespace (for example "kubernetes") and operation (for
+// example "get_resource"). Calls without a configured response fail.
+type fakeHostClient struct {
+	mu        sync.Mutex
+	handlers  map[string]hostHandler
+	resources map[string][]byte
+	calls     []hostCall
+}
+
+func newFakeHostClient() *fakeHostClient {
+	f := &fakeHostClient{
+		handlers:  map[string]hostHandler{},
+		resources: map[string][]byte{},
+	}
+	f.Handle("kubernetes", "get_resource", f.getResource)
+
+	return f
+}
+
+// newEvaluatorWithHost creates an evaluator that talks to the given host.
+func newEvaluatorWithHost(host HostClient) *evaluator {
+	ev := newEvaluator()
+	ev.host = host
+
+	return ev
+}
+
+func fakeHostKey(namespace, operation string) string {
+	return namespace + "/" + operation
+}
+
+// Handle computes the responses of the given operation with a function.
+func (f *fakeHostClient) Handle(namespace, operation string, handler hostHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[fakeHostKey(namespace, operation)] = handler
+}
+
+// Respond makes the given operation always return the given response.
+func (f *fakeHostClient) Respond(namespace, operation string, response []byte) {
+	f.Handle(namespace, operation, func([]byte) ([]byte, error) {
+		return response, nil
+	})
+}
+
+// RespondJSON makes the given operation always return the JSON
+// representation of the given object.
+func (f *fakeHostClient) RespondJSON(t *testing.T, namespace, operation string, response interface{}) {
+	t.Helper()
+
+	payload, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Cannot serialize canned response: %+v", err)
+	}
+	f.Respond(namespace, operation, payload)
+}
+
+// Fail makes the given operation always return the given error.
+func (f *fakeHostClient) Fail(namespace, operation string, err error) {
+	f.Handle(namespace, operation, func([]byte) ([]byte, error) {
+		return nil, err
+	})
+}
+
+// AddResource makes the given object available through the `get_resource`
+// Kubernetes operation.
+func (f *fakeHostClient) AddResource(t *testing.T, apiVersion, kind, namespace, name string, object interface{}) {
+	t.Helper()
+
+	payload, err := json.Marshal(object)
+	if err != nil {
+		t.Fatalf("Cannot serialize resource: %+v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resources[fmt.Sprintf("%s/%s/%s/%s", apiVersion, kind, namespace, name)] = payload
+}
+
+func (f *fakeHostClient) getResource(payload []byte) ([]byte, error) {
+	request := kubernetes.GetResourceRequest{}
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, err
+	}
+	namespace := ""
+	if request.Namespace != nil {
+		namespace = *request.Namespace
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resource, found := f.resources[fmt.Sprintf("%s/%s/%s/%s", request.APIVersion, request.Kind, namespace, request.Name)]
+	if !found {
+		return nil, fmt.Errorf("%s %s/%s not found", request.Kind, namespace, request.Name)
+	}
+
+	return resource, nil
+}
+
+func (f *fakeHostClient) HostCall(binding, namespace, operation string, payload []byte) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, hostCall{
+		Binding:   binding,
+		Namespace: namespace,
+		Operation: operation,
+		Payload:   payload,
+	})
+	handler, found := f.handlers[fakeHostKey(namespace, operation)]
+	f.mu.Unlock()
+
+	if binding != "kubewarden" {
+		return nil, fmt.Errorf("unknown binding %q", binding)
+	}
+	if !found {
+		return nil, fmt.Errorf("no canned response for %s/%s", namespace, operation)
+	}
+
+	return handler(payload)
+}
+
+// Calls returns the calls made to the given operation.
+func (f *fakeHostClient) Calls(namespace, operation string) []hostCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := []hostCall{}
+	for _, call := range f.calls {
+		if call.Namespace == namespace && call.Operation == operation {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func TestFakeHostClient(t *testing.T) {
+	host := newFakeHostClient()
+	host.Respond("net", "v1/dns_lookup_host", []byte(`{"ips":["10.0.0.1"]}`))
+	host.Fail("crypto", "v1/is_certificate_trusted", fmt.Errorf("boom"))
+
+	response, err := host.HostCall("kubewarden", "net", "v1/dns_lookup_host", []byte(`"example.com"`))
+	if err != nil || string(response) != `{"ips":["10.0.0.1"]}` {
+		t.Errorf("Unexpected response %s, error: %+v", response, err)
+	}
+	if _, err = host.HostCall("kubewarden", "crypto", "v1/is_certificate_trusted", nil); err == nil {
+		t.Errorf("Expected an error")
+	}
+	if _, err = host.HostCall("kubewarden", "oci", "v1/manifest_digest", nil); err == nil {
+		t.Errorf("Expected an error for an operation without canned responses")
+	}
+
+	calls := host.Calls("net", "v1/dns_lookup_host")
+	if len(calls) != 1 || string(calls[0].Payload) != `"example.com"` {
+		t.Errorf("Unexpected calls: %+v", calls)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !tinygo
+
+package main
+
+import (
+	"errors"
+)
+
+// nativeHostClient is used when the policy is not running inside of the
+// Kubewarden host, for example when running `go test`. The native waPC stub
+// reports every call as successful with an empty response, this client fails
+// instead, to avoid mistaking an empty response for an actual one.
+type nativeHostClient struct{}
+
+func (c *nativeHostClient) HostCall(_, _, _ string, _ []byte) ([]byte, error) {
+	return nil, errors.New("host capabilities are available only inside of the Kubewarden host")
+}
+
+// newHostClient creates a client that reports all the host capabilities as
+// unavailable.
+func newHostClient() HostClient {
+	return &nativeHostClient{}
+}
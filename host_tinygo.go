@@ -0,0 +1,19 @@
+//go:build tinygo
+
+package main
+
+import (
+	wapc "github.com/wapc/wapc-guest-tinygo"
+)
+
+// wapcHostClient calls the Kubewarden host through waPC.
+type wapcHostClient struct{}
+
+func (c *wapcHostClient) HostCall(binding, namespace, operation string, payload []byte) ([]byte, error) {
+	return wapc.HostCall(binding, namespace, operation, payload)
+}
+
+// newHostClient creates a client that talks to the Kubewarden host.
+func newHostClient() HostClient {
+	return &wapcHostClient{}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"path"
+	"strings"
+
+	onelog "github.com/francoispqt/onelog"
+	networkingv1 "github.com/kubewarden/k8s-objects/api/networking/v1"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/net"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+// IngressHostSettings describes the rules applied to the hostnames of
+// Ingress objects, which are the ones of `spec.rules[].host` and of
+// `spec.tls[].hosts`.
+type IngressHostSettings struct {
+	// DeniedHosts are glob patterns of the hostnames that cannot be used,
+	// for example "*.internal.example.com". `*` matches any sequence of
+	// characters, dots included.
+	DeniedHosts []string `json:"denied_hosts,omitempty"`
+	// DNSCheck ensures the hostnames resolve to allowed addresses.
+	DNSCheck *IngressDNSCheck `json:"dns_check,omitempty"`
+}
+
+// IngressDNSCheck ensures the hostnames of Ingress objects resolve only to
+// addresses inside of the allowed networks. The lookups are performed by the
+// Kubewarden host. Wildcard hostnames are not checked.
+type IngressDNSCheck struct {
+	// AllowedCIDRs are the networks the hostnames can resolve to, for
+	// example "203.0.113.0/24".
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+	// FailurePolicy decides what happens when a hostname cannot be
+	// resolved: "fail-closed" (the default) rejects the request,
+	// "fail-open" skips the hostname.
+	FailurePolicy string `json:"failure_policy,omitempty"`
+}
+
+// Validate ensures the patterns and the networks can be parsed.
+func (i *IngressHostSettings) Validate() error {
+	for n, pattern := range i.DeniedHosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("ingress_hosts.denied_hosts[%d]: invalid pattern %q: %w", n, pattern, err)
+		}
+	}
+
+	if i.DNSCheck == nil {
+		return nil
+	}
+	if len(i.DNSCheck.AllowedCIDRs) == 0 {
+		return errors.New("ingress_hosts.dns_check: at least one allowed CIDR must be provided")
+	}
+	if _, err := i.DNSCheck.prefixes(); err != nil {
+		return err
+	}
+
+	return validateFailurePolicy("ingress_hosts.dns_check.failure_policy", i.DNSCheck.FailurePolicy)
+}
+
+// IsHostDenied returns true when the hostname matches one of the denied
+// patterns.
+func (i *IngressHostSettings) IsHostDenied(host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range i.DeniedHosts {
+		if matched, err := path.Match(strings.ToLower(pattern), host); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *IngressDNSCheck) prefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(d.AllowedCIDRs))
+	for n, cidr := range d.AllowedCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("ingress_hosts.dns_check.allowed_cidrs[%d]: %w", n, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// ingressHosts returns the hostnames used by the given Ingress, without
+// duplicates.
+func ingressHosts(ingress *networkingv1.Ingress) []string {
+	hosts := []string{}
+	if ingress.Spec == nil {
+		return hosts
+	}
+
+	seen := map[string]bool{}
+	add := func(host string) {
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	for _, rule := range ingress.Spec.Rules {
+		add(rule.Host)
+	}
+	for _, tls := range ingress.Spec.TLS {
+		for _, host := range tls.Hosts {
+			add(host)
+		}
+	}
+
+	return hosts
+}
+
+// hostAddressViolation resolves the hostname and returns the violation
+// message when one of its addresses is outside of the allowed networks. An
+// error is returned when the hostname cannot be resolved.
+func (ev *evaluator) hostAddressViolation(prefixes []netip.Prefix, host string) (string, error) {
+	ips, err := net.LookupHost(ev.capabilitiesHost(), host)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse address %q: %w", ip, err)
+		}
+		allowed := false
+		for _, prefix := range prefixes {
+			allowed = allowed || prefix.Contains(addr.Unmap())
+		}
+		if !allowed {
+			return fmt.Sprintf("The '%s' host resolves to %s, which is outside of the allowed CIDRs", host, addr), nil
+		}
+	}
+
+	return "", nil
+}
+
+// validateIngress validates the Ingresses, which are subject only to the
+// checks of their hostnames.
+func (ev *evaluator) validateIngress(
+	settings *Settings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+) ([]byte, error) {
+	if settings.IngressHosts == nil || request.Operation == operationDelete {
+		return kubewarden.AcceptRequest()
+	}
+
+	ingress := networkingv1.Ingress{}
+	if err := json.Unmarshal(request.Object, &ingress); err != nil {
+		return kubewarden.RejectRequest(
+			kubewarden.Message(fmt.Sprintf("Cannot decode Ingress object: %s", err.Error())),
+			kubewarden.Code(httpBadRequestStatusCode))
+	}
+
+	if violations := ev.ingressHostViolations(settings, request, &ingress); len(violations) > 0 {
+		return kubewarden.RejectRequest(kubewarden.Message(strings.Join(violations, "; ")), kubewarden.NoCode)
+	}
+
+	return kubewarden.AcceptRequest()
+}
+
+// ingressHostViolations checks the hostnames of the Ingress and returns the
+// messages of the violated rules.
+func (ev *evaluator) ingressHostViolations(
+	settings *Settings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+	ingress *networkingv1.Ingress,
+) []string {
+	violations := []string{}
+	hosts := ingressHosts(ingress)
+
+	denied := []string{}
+	for _, host := range hosts {
+		if settings.IngressHosts.IsHostDenied(host) {
+			denied = append(denied, host)
+		}
+	}
+	if len(denied) > 0 {
+		violations = append(violations,
+			fmt.Sprintf("The following Ingress hosts are on the deny list: %s", strings.Join(denied, ", ")))
+	}
+
+	dnsCheck := settings.IngressHosts.DNSCheck
+	if dnsCheck == nil {
+		return violations
+	}
+	prefixes, err := dnsCheck.prefixes()
+	if err != nil {
+		return append(violations, err.Error())
+	}
+	for _, host := range hosts {
+		if strings.HasPrefix(host, "*") {
+			continue
+		}
+		violation, err := ev.hostAddressViolation(prefixes, host)
+		switch {
+		case err != nil && dnsCheck.FailurePolicy == failOpen:
+			ev.logger.WarnWithFields("cannot resolve Ingress host, ignoring it", func(e onelog.Entry) {
+				e.String("name", request.Name)
+				e.String("host", host)
+				e.String("error", err.Error())
+			})
+		case err != nil:
+			violations = append(violations, fmt.Sprintf("Cannot resolve the '%s' host: %s", host, err.Error()))
+		case violation != "":
+			violations = append(violations, violation)
+		}
+	}
+
+	return violations
+}
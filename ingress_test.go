@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+const ingressObject = `{
+	"apiVersion": "networking.k8s.io/v1",
+	"kind": "Ingress",
+	"metadata": {"name": "shop", "namespace": "shop"},
+	"spec": {
+		"rules": [
+			{"host": "shop.example.com", "http": {"paths": []}},
+			{"host": "admin.internal.example.com"}
+		],
+		"tls": [{"hosts": ["shop.example.com", "*.cdn.example.com"], "secretName": "shop-tls"}]
+	}
+}`
+
+// resolveHosts makes the DNS lookup capability resolve the given hostnames,
+// and fail for all the other ones.
+func resolveHosts(host *fakeHostClient, addresses map[string][]string) {
+	host.Handle("net", "v1/dns_lookup_host", func(payload []byte) ([]byte, error) {
+		var hostname string
+		if err := json.Unmarshal(payload, &hostname); err != nil {
+			return nil, err
+		}
+		ips, found := addresses[hostname]
+		if !found {
+			return nil, errors.New("no such host")
+		}
+		return json.Marshal(map[string][]string{"ips": ips})
+	})
+}
+
+func TestIsHostDenied(t *testing.T) {
+	settings := IngressHostSettings{DeniedHosts: []string{"*.internal.example.com", "example.org"}}
+
+	cases := map[string]bool{
+		"admin.internal.example.com": true,
+		"a.b.internal.example.com":   true,
+		"ADMIN.Internal.Example.com": true,
+		"internal.example.com":       false,
+		"example.org":                true,
+		"shop.example.org":           false,
+		"shop.example.com":           false,
+	}
+	for host, expected := range cases {
+		if settings.IsHostDenied(host) != expected {
+			t.Errorf("IsHostDenied(%q) should be %v", host, expected)
+		}
+	}
+}
+
+func TestIngressDeniedHosts(t *testing.T) {
+	settings := &Settings{
+		DeniedNames:  []DenyEntry{},
+		IngressHosts: &IngressHostSettings{DeniedHosts: []string{"*.internal.example.com", "*.cdn.example.com"}},
+	}
+
+	response := newRequest(t, ingressObject).Settings(settings).Validate(newEvaluator())
+	if response.Accepted {
+		t.Fatalf("Unexpected acceptance")
+	}
+	expected := "The following Ingress hosts are on the deny list: admin.internal.example.com, *.cdn.example.com"
+	if *response.Message != expected {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expected)
+	}
+}
+
+func TestIngressOnlyChecksTheHosts(t *testing.T) {
+	now := time.Date(2026, 12, 24, 0, 0, 0, 0, time.UTC)
+	settings := &Settings{
+		DeniedNames: denyEntries("shop"),
+		FreezeWindows: &FreezeSettings{
+			Absolute: []AbsoluteWindow{{Name: "holidays", Start: now.AddDate(0, 0, -1), End: now.AddDate(0, 0, 1)}},
+		},
+		NameCollision: &NameCollisionSettings{},
+		IngressHosts:  &IngressHostSettings{DeniedHosts: []string{"example.org"}},
+	}
+	host := newFakeHostClient()
+	ev := newEvaluatorWithHost(host)
+	ev.clock = fixedClock(now)
+
+	response := newRequest(t, ingressObject).Settings(settings).Validate(ev)
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+	if calls := host.Calls("kubernetes", "list_resources_all"); len(calls) != 0 {
+		t.Errorf("Unexpected lookups: %+v", calls)
+	}
+}
+
+func TestIngressThatCannotBeDecoded(t *testing.T) {
+	settings := &Settings{DeniedNames: []DenyEntry{}, IngressHosts: &IngressHostSettings{}}
+
+	response := newRequest(t, `{"kind": "Ingress", "metadata": {"name": "shop"}, "spec": {"rules": 42}}`).
+		Settings(settings).Validate(newEvaluator())
+	if response.Accepted {
+		t.Fatalf("Unexpected acceptance")
+	}
+	if response.Code == nil || *response.Code != httpBadRequestStatusCode {
+		t.Errorf("Expected code %d, got %v", httpBadRequestStatusCode, response.Code)
+	}
+}
+
+func TestIngressDNSCheck(t *testing.T) {
+	cases := []struct {
+		name          string
+		addresses     map[string][]string
+		failurePolicy string
+		expected      string
+	}{
+		{
+			name: "allowed addresses",
+			addresses: map[string][]string{
+				"shop.example.com":           {"203.0.113.10", "2001:db8::10"},
+				"admin.internal.example.com": {"10.1.2.3"},
+			},
+		},
+		{
+			name: "public hostname routed to an internal address",
+			addresses: map[string][]string{
+				"shop.example.com":           {"203.0.113.10", "192.168.1.1"},
+				"admin.internal.example.com": {"10.1.2.3"},
+			},
+			expected: "The 'shop.example.com' host resolves to 192.168.1.1, which is outside of the allowed CIDRs",
+		},
+		{
+			name:      "lookup failure with fail-closed policy",
+			addresses: map[string][]string{"shop.example.com": {"203.0.113.10"}},
+			expected:  "Cannot resolve the 'admin.internal.example.com' host: no such host",
+		},
+		{
+			name:          "lookup failure with fail-open policy",
+			addresses:     map[string][]string{"shop.example.com": {"203.0.113.10"}},
+			failurePolicy: failOpen,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := newFakeHostClient()
+			resolveHosts(host, tc.addresses)
+			settings := &Settings{
+				DeniedNames: []DenyEntry{},
+				IngressHosts: &IngressHostSettings{
+					DNSCheck: &IngressDNSCheck{
+						AllowedCIDRs:  []string{"203.0.113.0/24", "10.0.0.0/8", "2001:db8::/32"},
+						FailurePolicy: tc.failurePolicy,
+					},
+				},
+			}
+
+			response := newRequest(t, ingressObject).Settings(settings).Validate(newEvaluatorWithHost(host))
+			if tc.expected == "" {
+				if !response.Accepted {
+					t.Errorf("Unexpected rejection: %s", *response.Message)
+				}
+			} else if response.Accepted || *response.Message != tc.expected {
+				t.Errorf("Expected rejection '%s', got: %+v", tc.expected, response)
+			}
+
+			// wildcard hostnames are never resolved
+			for _, call := range host.Calls("net", "v1/dns_lookup_host") {
+				if string(call.Payload) == `"*.cdn.example.com"` {
+					t.Errorf("Wildcard hostname should not be resolved")
+				}
+			}
+		})
+	}
+}
+
+func TestIngressHostSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings IngressHostSettings
+		expected string
+	}{
+		{
+			name:     "invalid pattern",
+			settings: IngressHostSettings{DeniedHosts: []string{"[example.com"}},
+			expected: `ingress_hosts.denied_hosts[0]: invalid pattern "[example.com": syntax error in pattern`,
+		},
+		{
+			name:     "no CIDR",
+			settings: IngressHostSettings{DNSCheck: &IngressDNSCheck{}},
+			expected: "ingress_hosts.dns_check: at least one allowed CIDR must be provided",
+		},
+		{
+			name:     "invalid CIDR",
+			settings: IngressHostSettings{DNSCheck: &IngressDNSCheck{AllowedCIDRs: []string{"10.0.0.0"}}},
+			expected: `ingress_hosts.dns_check.allowed_cidrs[0]: netip.ParsePrefix("10.0.0.0"): no '/'`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := Settings{DeniedNames: []DenyEntry{}, IngressHosts: &tc.settings}
+			valid, err := settings.Valid()
+			if valid || err == nil || err.Error() != tc.expected {
+				t.Errorf("Expected error '%s', got valid: %v, error: %v", tc.expected, valid, err)
+			}
+		})
+	}
+}
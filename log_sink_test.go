@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	onelog "github.com/francoispqt/onelog"
+)
+
+// logEntry is a log line written by the policy, decoded from the JSON
+// produced by onelog. The level and the message are stored under the
+// "level" and "message" keys, next to the fields of the entry.
+type logEntry map[string]interface{}
+
+// String returns the value of the given field, or an empty string when the
+// field is missing or isn't a string.
+func (e logEntry) String(key string) string {
+	value, _ := e[key].(string)
+	return value
+}
+
+// logSink collects the logs of an evaluator, so that the tests can make
+// assertions on them instead of scraping the standard output:
+//
+//	logs := newLogSink(t)
+//	ev.logger = logs.Logger()
+type logSink struct {
+	t       *testing.T
+	mutex   sync.Mutex
+	lines   []string
+	entries []logEntry
+}
+
+func newLogSink(t *testing.T) *logSink {
+	return &logSink{t: t}
+}
+
+// Logger returns a logger writing into the sink, with all the levels
+// enabled.
+func (s *logSink) Logger() *onelog.Logger {
+	return onelog.New(s, onelog.ALL)
+}
+
+// Write decodes the log lines written by onelog, failing the test when a
+// line isn't valid JSON.
+func (s *logSink) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, line := range bytes.Split(bytes.TrimSpace(p), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		entry := logEntry{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			s.t.Errorf("Cannot decode log line %q: %+v", line, err)
+			continue
+		}
+		s.lines = append(s.lines, string(line))
+		s.entries = append(s.entries, entry)
+	}
+
+	return len(p), nil
+}
+
+// Entries returns the entries logged so far.
+func (s *logSink) Entries() []logEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]logEntry{}, s.entries...)
+}
+
+// Find returns the first entry with the given level and message, failing
+// the test when there is none.
+func (s *logSink) Find(level, message string) logEntry {
+	s.t.Helper()
+
+	for _, entry := range s.Entries() {
+		if entry.String("level") == level && entry.String("message") == message {
+			return entry
+		}
+	}
+	s.t.Fatalf("No %s entry with message %q has been logged, got: %s", level, message, s.String())
+
+	return nil
+}
+
+// AssertNotLogged fails the test when any of the given values shows up in
+// the logs, either as a field or as part of one.
+func (s *logSink) AssertNotLogged(values ...string) {
+	s.t.Helper()
+
+	logs := s.String()
+	for _, value := range values {
+		if strings.Contains(logs, value) {
+			s.t.Errorf("%q has been logged: %s", value, logs)
+		}
+	}
+}
+
+// String returns all the log lines collected so far.
+func (s *logSink) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return strings.Join(s.lines, "\n")
+}
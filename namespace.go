@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	onelog "github.com/francoispqt/onelog"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+)
+
+// lookupNamespace fetches the given Namespace through the Kubernetes
+// capability of the Kubewarden host.
+func (ev *evaluator) lookupNamespace(name string) (*corev1.Namespace, error) {
+	responsePayload, err := ev.getResource("v1", "Namespace", "", name)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := corev1.Namespace{}
+	if err = json.Unmarshal(responsePayload, &namespace); err != nil {
+		return nil, fmt.Errorf("cannot decode Namespace object: %w", err)
+	}
+	if namespace.Metadata == nil {
+		return nil, errors.New("the Namespace object has no metadata")
+	}
+
+	return &namespace, nil
+}
+
+// namespaceInScope returns true when the deny rules apply to objects of the
+// given namespace. When the namespace cannot be looked up the deny rules are
+// applied, to avoid letting objects through because of a transient failure.
+func (ev *evaluator) namespaceInScope(settings *Settings, namespace string) bool {
+	if settings.NamespaceSelector == "" {
+		return true
+	}
+
+	// the selector has already been validated together with the settings
+	selector, err := ParseLabelSelector(settings.NamespaceSelector)
+	if err != nil {
+		return true
+	}
+
+	ns, err := ev.lookupNamespace(namespace)
+	if err != nil {
+		ev.logger.WarnWithFields("cannot look up namespace, applying deny rules", func(e onelog.Entry) {
+			e.String("namespace", namespace)
+			e.String("error", err.Error())
+		})
+		return true
+	}
+
+	return selector.Matches(ns.Metadata.Labels)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNamespaceSelectorRequiresContextAwareMode(t *testing.T) {
+	settings := Settings{NamespaceSelector: "env=prod"}
+	valid, err := settings.Valid()
+	if valid || err == nil {
+		t.Errorf("Expected settings to be invalid")
+	}
+
+	settings.ContextAware = true
+	valid, err = settings.Valid()
+	if !valid || err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+
+	settings.NamespaceSelector = "env in (prod)"
+	valid, err = settings.Valid()
+	if valid || err == nil {
+		t.Errorf("Expected settings to be invalid")
+	}
+}
+
+// namespaceSelectorSettings enforce the deny list inside of the production
+// namespaces only.
+func namespaceSelectorSettings() *Settings {
+	return &Settings{
+		DeniedNames:       denyEntries("test-pod"),
+		ContextAware:      true,
+		NamespaceSelector: "env=prod",
+	}
+}
+
+func TestDenyRulesApplyOnlyToSelectedNamespaces(t *testing.T) {
+	host := newFakeHostClient()
+	host.AddResource(t, "v1", "Namespace", "", "shop", corev1.Namespace{
+		Metadata: &metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"env": "prod"}},
+	})
+	host.AddResource(t, "v1", "Namespace", "", "sandbox", corev1.Namespace{
+		Metadata: &metav1.ObjectMeta{Name: "sandbox", Labels: map[string]string{"env": "dev"}},
+	})
+
+	if response := newRequest(t, testPod("test-pod", "shop")).Settings(namespaceSelectorSettings()).Validate(newEvaluatorWithHost(host)); response.Accepted {
+		t.Errorf("Unexpected approval inside of a selected namespace")
+	}
+	if response := newRequest(t, testPod("test-pod", "sandbox")).Settings(namespaceSelectorSettings()).Validate(newEvaluatorWithHost(host)); !response.Accepted {
+		t.Errorf("Unexpected rejection outside of the selected namespaces")
+	}
+}
+
+func TestDenyRulesApplyWhenNamespaceLookupFails(t *testing.T) {
+	host := newFakeHostClient()
+	host.Fail("kubernetes", "get_resource", errors.New("timeout"))
+
+	if response := newRequest(t, testPod("test-pod", "shop")).Settings(namespaceSelectorSettings()).Validate(newEvaluatorWithHost(host)); response.Accepted {
+		t.Error("Unexpected approval")
+	}
+	if calls := host.Calls("kubernetes", "get_resource"); len(calls) != 1 {
+		t.Errorf("Expected one lookup, got %d", len(calls))
+	}
+
+	// outside of the Kubewarden host the lookup always fails
+	if response := newRequest(t, testPod("test-pod", "shop")).Settings(namespaceSelectorSettings()).Validate(newEvaluator()); response.Accepted {
+		t.Error("Unexpected approval")
+	}
+}
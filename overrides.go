@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	onelog "github.com/francoispqt/onelog"
+)
+
+const defaultSettingsOverrideAnnotation = "policy.example.com/settings-override"
+
+// NamespaceOverrideSettings lets namespaces carry an annotation holding a
+// JSON object that is merged over the settings of the policy, when
+// evaluating the objects of that namespace.
+//
+// The merge is additive: lists are appended to the ones of the settings and
+// objects are merged key by key, while all the other values are replaced.
+// Only the allowed top-level keys can be overridden, and never the settings
+// that could relax the rules of the policy, which lets tenants add their own
+// deny entries without relaxing the global ones.
+type NamespaceOverrideSettings struct {
+	// Annotation holding the override. Defaults to
+	// "policy.example.com/settings-override".
+	Annotation string `json:"annotation,omitempty"`
+	// AllowedKeys are the top-level settings keys that can be overridden,
+	// for example "denied_names".
+	AllowedKeys []string `json:"allowed_keys"`
+}
+
+// AnnotationName returns the name of the annotation holding the override.
+func (n *NamespaceOverrideSettings) AnnotationName() string {
+	if n.Annotation == "" {
+		return defaultSettingsOverrideAnnotation
+	}
+
+	return n.Annotation
+}
+
+// Validate ensures the allowed keys do not include the ones that control the
+// overrides themselves, nor the ones that could relax the rules.
+func (n *NamespaceOverrideSettings) Validate() error {
+	if len(n.AllowedKeys) == 0 {
+		return errors.New("namespace_overrides: at least one allowed key must be provided")
+	}
+	for i, key := range n.AllowedKeys {
+		if relaxingSetting(key) {
+			return fmt.Errorf("namespace_overrides.allowed_keys[%d]: '%s' cannot be overridden", i, key)
+		}
+	}
+
+	return nil
+}
+
+// relaxingSetting returns true when overriding the setting at the given path,
+// made of the keys joined by dots, could relax the rules of the policy:
+// either its value cannot be made stricter by a merge, or appending to it
+// exempts some objects or users.
+func relaxingSetting(path string) bool {
+	switch path {
+	case "namespace_overrides", "context_aware", "break_glass", "rollout_percentage",
+		"namespace_rollout_percentages", "rollout_key", "namespace_selector", "access_review_exemption",
+		"deny_list_source", "freeze_windows.kinds", "freeze_windows.exempt_users", "freeze_windows.exempt_groups",
+		"image_signatures.annotations", "name_collision.action", "name_collision.ignored_namespaces", "digest_pinning.failure_policy",
+		"tls_secrets.ca_bundles", "tls_secrets.min_validity", "ingress_hosts.dns_check":
+		return true
+	}
+
+	return false
+}
+
+// jsonType returns the name of the JSON type of a decoded value.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func (n *NamespaceOverrideSettings) isAllowed(key string) bool {
+	for _, allowed := range n.AllowedKeys {
+		if allowed == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mergeValues merges the override over the base value found at the given
+// path: lists are appended and objects are merged key by key. Overrides that
+// are null, that change the type of the base value, that replace a value of
+// the base or that touch a setting of the base that could relax the rules are
+// rejected.
+func mergeValues(path string, base, override interface{}) (interface{}, error) {
+	if override == nil {
+		return nil, fmt.Errorf("'%s' cannot be null", path)
+	}
+	if base != nil && jsonType(base) != jsonType(override) {
+		return nil, fmt.Errorf("'%s' must be of type %s, like in the settings of the policy", path, jsonType(base))
+	}
+
+	switch o := override.(type) {
+	case []interface{}:
+		if b, ok := base.([]interface{}); ok {
+			return append(append([]interface{}{}, b...), o...), nil
+		}
+	case map[string]interface{}:
+		if b, ok := base.(map[string]interface{}); ok {
+			merged := make(map[string]interface{}, len(b)+len(o))
+			for key, value := range b {
+				merged[key] = value
+			}
+			for key, value := range o {
+				keyPath := key
+				if path != "" {
+					keyPath = path + "." + key
+				}
+				if relaxingSetting(keyPath) {
+					return nil, fmt.Errorf("'%s' cannot be overridden, it could relax the rules of the policy", keyPath)
+				}
+				var err error
+				if merged[key], err = mergeValues(keyPath, b[key], value); err != nil {
+					return nil, err
+				}
+			}
+			return merged, nil
+		}
+	default:
+		if base != nil {
+			return nil, fmt.Errorf("'%s' is set by the settings of the policy, it cannot be replaced", path)
+		}
+	}
+
+	return override, nil
+}
+
+// mergeSettingsOverride merges the given override over the raw settings of
+// the policy and returns the resulting settings, which are validated.
+func mergeSettingsOverride(overrides *NamespaceOverrideSettings, rawSettings []byte, override string) (Settings, error) {
+	overrideValues := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(override), &overrideValues); err != nil {
+		return Settings{}, fmt.Errorf("the '%s' annotation is not a JSON object: %w", overrides.AnnotationName(), err)
+	}
+
+	keys := make([]string, 0, len(overrideValues))
+	for key := range overrideValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !overrides.isAllowed(key) {
+			return Settings{}, fmt.Errorf("the '%s' key cannot be overridden, the allowed keys are: %s",
+				key, strings.Join(overrides.AllowedKeys, ", "))
+		}
+	}
+
+	baseValues := map[string]interface{}{}
+	if len(rawSettings) > 0 {
+		if err := json.Unmarshal(rawSettings, &baseValues); err != nil {
+			return Settings{}, err
+		}
+	}
+
+	mergedValues, err := mergeValues("", baseValues, overrideValues)
+	if err != nil {
+		return Settings{}, err
+	}
+	merged, err := json.Marshal(mergedValues)
+	if err != nil {
+		return Settings{}, err
+	}
+	settings := Settings{}
+	if err = json.Unmarshal(merged, &settings); err != nil {
+		return Settings{}, err
+	}
+	if _, err = settings.Valid(); err != nil {
+		return Settings{}, err
+	}
+
+	return settings, nil
+}
+
+// applyNamespaceOverride returns the settings to use for the objects of the
+// given namespace. The settings of the policy are used when the namespace
+// cannot be looked up or has no override. An error is returned when the
+// override is not valid.
+func (ev *evaluator) applyNamespaceOverride(settings Settings, rawSettings []byte, namespace string) (Settings, error) {
+	overrides := settings.NamespaceOverrides
+	if overrides == nil || namespace == "" {
+		return settings, nil
+	}
+
+	ns, err := ev.lookupNamespace(namespace)
+	if err != nil {
+		ev.logger.WarnWithFields("cannot look up namespace, ignoring its settings override", func(e onelog.Entry) {
+			e.String("namespace", namespace)
+			e.String("error", err.Error())
+		})
+		return settings, nil
+	}
+	override, found := ns.Metadata.Annotations[overrides.AnnotationName()]
+	if !found {
+		return settings, nil
+	}
+
+	merged, err := mergeSettingsOverride(overrides, rawSettings, override)
+	if err != nil {
+		return Settings{}, fmt.Errorf("Invalid settings override of the '%s' namespace: %w", //nolint:staticcheck // shown to the user as is
+			namespace, err)
+	}
+
+	ev.logger.DebugWithFields("applying namespace settings override", func(e onelog.Entry) {
+		e.String("namespace", namespace)
+	})
+
+	return merged, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+func overrideSettings() *Settings {
+	return &Settings{
+		DeniedNames:  denyEntries("global-pod"),
+		ContextAware: true,
+		NamespaceOverrides: &NamespaceOverrideSettings{
+			AllowedKeys: []string{"denied_names", "ingress_hosts"},
+		},
+	}
+}
+
+// namespaceWithOverride creates a fake host serving the given namespace,
+// annotated with the given override.
+func namespaceWithOverride(t *testing.T, namespace, override string) *fakeHostClient {
+	t.Helper()
+
+	metadata := &metav1.ObjectMeta{Name: namespace}
+	if override != "" {
+		metadata.Annotations = map[string]string{defaultSettingsOverrideAnnotation: override}
+	}
+	host := newFakeHostClient()
+	host.AddResource(t, "v1", "Namespace", "", namespace, corev1.Namespace{Metadata: metadata})
+
+	return host
+}
+
+func TestMergeValues(t *testing.T) {
+	cases := []struct {
+		name     string
+		base     string
+		override string
+		expected string
+	}{
+		{
+			name:     "lists are appended",
+			base:     `{"denied_names": ["a", {"name": "b"}]}`,
+			override: `{"denied_names": ["c"]}`,
+			expected: `{"denied_names": ["a", {"name": "b"}, "c"]}`,
+		},
+		{
+			name:     "objects are merged",
+			base:     `{"ingress_hosts": {"denied_hosts": ["*.internal"]}, "freeze_windows": {"kinds": ["Pod"]}}`,
+			override: `{"ingress_hosts": {"denied_hosts": ["*.corp"]}, "freeze_windows": {"weekly": []}}`,
+			expected: `{"ingress_hosts": {"denied_hosts": ["*.internal", "*.corp"]},
+				"freeze_windows": {"kinds": ["Pod"], "weekly": []}}`,
+		},
+		{
+			name:     "missing keys are added",
+			base:     `{}`,
+			override: `{"denied_names": ["a"], "freeze_windows": {"exempt_users": ["alice"]}}`,
+			expected: `{"denied_names": ["a"], "freeze_windows": {"exempt_users": ["alice"]}}`,
+		},
+		{
+			name:     "null values of the policy are replaced",
+			base:     `{"denied_names": null}`,
+			override: `{"denied_names": ["a"]}`,
+			expected: `{"denied_names": ["a"]}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, err := mergeValues("", decodeJSON(t, tc.base), decodeJSON(t, tc.override))
+			if err != nil {
+				t.Fatalf("Unexpected error: %+v", err)
+			}
+			if !reflect.DeepEqual(merged, decodeJSON(t, tc.expected)) {
+				t.Errorf("Got %+v instead of %s", merged, tc.expected)
+			}
+		})
+	}
+}
+
+func TestMergeValuesCannotRelaxTheSettings(t *testing.T) {
+	base := `{
+		"denied_names": ["a"],
+		"freeze_windows": {"kinds": ["Pod"], "exempt_users": ["root"]},
+		"image_signatures": {"pub_keys": ["pem"], "annotations": {"env": "prod"}},
+		"name_collision": {"action": "reject"},
+		"tls_secrets": {"ca_bundles": ["pem"], "min_validity": "720h"},
+		"ingress_hosts": {"denied_hosts": ["*.internal"], "dns_check": {"allowed_cidrs": ["10.0.0.0/8"]}}
+	}`
+	cases := []struct {
+		override string
+		expected string
+	}{
+		{`{"denied_names": null}`, "'denied_names' cannot be null"},
+		{`{"denied_names": "a"}`, "'denied_names' must be of type array, like in the settings of the policy"},
+		{`{"ingress_hosts": {"denied_hosts": {}}}`, "'ingress_hosts.denied_hosts' must be of type array, like in the settings of the policy"},
+		{`{"break_glass": {"keys": [{"id": "tenant", "secret": "c2VjcmV0"}]}}`, "'break_glass' cannot be overridden, it could relax the rules of the policy"},
+		{`{"rollout_percentage": 0}`, "'rollout_percentage' cannot be overridden, it could relax the rules of the policy"},
+		{`{"namespace_rollout_percentages": {"shop": 0}}`, "'namespace_rollout_percentages' cannot be overridden, it could relax the rules of the policy"},
+		{`{"namespace_selector": "env=none"}`, "'namespace_selector' cannot be overridden, it could relax the rules of the policy"},
+		{`{"access_review_exemption": {"verb": "get"}}`, "'access_review_exemption' cannot be overridden, it could relax the rules of the policy"},
+		{`{"freeze_windows": {"kinds": ["Deployment"]}}`, "'freeze_windows.kinds' cannot be overridden, it could relax the rules of the policy"},
+		{`{"freeze_windows": {"exempt_groups": ["shop"]}}`, "'freeze_windows.exempt_groups' cannot be overridden, it could relax the rules of the policy"},
+		{`{"image_signatures": {"annotations": {"env": "dev"}}}`, "'image_signatures.annotations' cannot be overridden, it could relax the rules of the policy"},
+		{`{"name_collision": {"action": "warn"}}`, "'name_collision.action' cannot be overridden, it could relax the rules of the policy"},
+		{`{"tls_secrets": {"ca_bundles": ["tenant-pem"]}}`, "'tls_secrets.ca_bundles' cannot be overridden, it could relax the rules of the policy"},
+		{`{"ingress_hosts": {"dns_check": {"failure_policy": "fail-open"}}}`, "'ingress_hosts.dns_check' cannot be overridden, it could relax the rules of the policy"},
+	}
+
+	for _, tc := range cases {
+		_, err := mergeValues("", decodeJSON(t, base), decodeJSON(t, tc.override))
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("%s: expected error '%s', got %v", tc.override, tc.expected, err)
+		}
+	}
+}
+
+func TestMergeValuesCannotReplaceValues(t *testing.T) {
+	cases := []struct {
+		base     string
+		override string
+	}{
+		{`{"env": "prod", "team": "a"}`, `{"team": "b"}`},
+		{`{"team": 1}`, `{"team": 2}`},
+		{`{"team": true}`, `{"team": false}`},
+	}
+
+	for _, tc := range cases {
+		_, err := mergeValues("image_signatures.annotations", decodeJSON(t, tc.base), decodeJSON(t, tc.override))
+		expected := "'image_signatures.annotations.team' is set by the settings of the policy, it cannot be replaced"
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error '%s', got %v", tc.override, expected, err)
+		}
+	}
+}
+
+// decodeJSON decodes the given JSON document into generic values.
+func decodeJSON(t *testing.T, raw string) interface{} {
+	t.Helper()
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	return value
+}
+
+func TestNamespaceOverrideAddsDenyEntries(t *testing.T) {
+	host := namespaceWithOverride(t, "shop", `{"denied_names": [{"name": "tenant-pod", "owner": "shop-team"}]}`)
+
+	cases := []struct {
+		name     string
+		accepted bool
+		message  string
+	}{
+		{"tenant-pod", false, "The 'tenant-pod' name is on the deny list (owner: shop-team)"},
+		{"global-pod", false, "The 'global-pod' name is on the deny list"},
+		{"other-pod", true, ""},
+	}
+
+	for _, tc := range cases {
+		response := newRequest(t, testPod(tc.name, "shop")).Settings(overrideSettings()).Validate(newEvaluatorWithHost(host))
+		if response.Accepted != tc.accepted {
+			t.Errorf("%s: got accepted=%v instead of %v", tc.name, response.Accepted, tc.accepted)
+		}
+		if !tc.accepted && *response.Message != tc.message {
+			t.Errorf("%s: got '%s' instead of '%s'", tc.name, *response.Message, tc.message)
+		}
+	}
+}
+
+func TestNamespaceWithoutOverrideUsesPolicySettings(t *testing.T) {
+	for name, host := range map[string]*fakeHostClient{
+		"no annotation":  namespaceWithOverride(t, "shop", ""),
+		"lookup failure": newFakeHostClient(),
+	} {
+		if response := newRequest(t, testPod("global-pod", "shop")).Settings(overrideSettings()).Validate(newEvaluatorWithHost(host)); response.Accepted {
+			t.Errorf("%s: expected the deny list of the policy to be enforced", name)
+		}
+		if response := newRequest(t, testPod("tenant-pod", "shop")).Settings(overrideSettings()).Validate(newEvaluatorWithHost(host)); !response.Accepted {
+			t.Errorf("%s: unexpected rejection: %s", name, *response.Message)
+		}
+	}
+}
+
+func TestInvalidNamespaceOverride(t *testing.T) {
+	cases := []struct {
+		name     string
+		override string
+		expected string
+	}{
+		{
+			name:     "key not allowed",
+			override: `{"denied_names": ["tenant-pod"], "rollout_percentage": 0}`,
+			expected: "Invalid settings override of the 'shop' namespace: " +
+				"the 'rollout_percentage' key cannot be overridden, the allowed keys are: denied_names, ingress_hosts",
+		},
+		{
+			name:     "not a JSON object",
+			override: `["tenant-pod"]`,
+			expected: "Invalid settings override of the 'shop' namespace: " +
+				"the 'policy.example.com/settings-override' annotation is not a JSON object: " +
+				"json: cannot unmarshal array into Go value of type map[string]interface {}",
+		},
+		{
+			name:     "invalid merged settings",
+			override: `{"denied_names": [{"reason": "missing name"}]}`,
+			expected: "Invalid settings override of the 'shop' namespace: denied_names[1]: name cannot be empty",
+		},
+		{
+			name:     "null value",
+			override: `{"denied_names": null}`,
+			expected: "Invalid settings override of the 'shop' namespace: 'denied_names' cannot be null",
+		},
+		{
+			name:     "invalid value",
+			override: `{"ingress_hosts": {"denied_hosts": "*.corp"}}`,
+			expected: "Invalid settings override of the 'shop' namespace: " +
+				"json: cannot unmarshal string into Go struct field Settings.ingress_hosts.denied_hosts of type []string",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := namespaceWithOverride(t, "shop", tc.override)
+
+			response := newRequest(t, testPod("other-pod", "shop")).Settings(overrideSettings()).Validate(newEvaluatorWithHost(host))
+			if response.Accepted {
+				t.Fatalf("Unexpected acceptance")
+			}
+			if *response.Message != tc.expected {
+				t.Errorf("Got '%s' instead of '%s'", *response.Message, tc.expected)
+			}
+			if response.Code == nil || *response.Code != httpBadRequestStatusCode {
+				t.Errorf("Unexpected code: %v", response.Code)
+			}
+		})
+	}
+}
+
+func TestNamespaceOverrideSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings Settings
+		expected string
+	}{
+		{
+			name: "requires context aware",
+			settings: Settings{
+				DeniedNames:        []DenyEntry{},
+				NamespaceOverrides: &NamespaceOverrideSettings{AllowedKeys: []string{"denied_names"}},
+			},
+			expected: "namespace_overrides requires context_aware to be enabled",
+		},
+		{
+			name: "no allowed keys",
+			settings: Settings{
+				DeniedNames:        []DenyEntry{},
+				ContextAware:       true,
+				NamespaceOverrides: &NamespaceOverrideSettings{},
+			},
+			expected: "namespace_overrides: at least one allowed key must be provided",
+		},
+		{
+			name: "context aware cannot be overridden",
+			settings: Settings{
+				DeniedNames:        []DenyEntry{},
+				ContextAware:       true,
+				NamespaceOverrides: &NamespaceOverrideSettings{AllowedKeys: []string{"denied_names", "context_aware"}},
+			},
+			expected: "namespace_overrides.allowed_keys[1]: 'context_aware' cannot be overridden",
+		},
+		{
+			name: "break glass cannot be overridden",
+			settings: Settings{
+				DeniedNames:        []DenyEntry{},
+				ContextAware:       true,
+				NamespaceOverrides: &NamespaceOverrideSettings{AllowedKeys: []string{"break_glass"}},
+			},
+			expected: "namespace_overrides.allowed_keys[0]: 'break_glass' cannot be overridden",
+		},
+		{
+			name: "rollout cannot be overridden",
+			settings: Settings{
+				DeniedNames:        []DenyEntry{},
+				ContextAware:       true,
+				NamespaceOverrides: &NamespaceOverrideSettings{AllowedKeys: []string{"denied_names", "rollout_percentage"}},
+			},
+			expected: "namespace_overrides.allowed_keys[1]: 'rollout_percentage' cannot be overridden",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.settings.Valid()
+			if valid || err == nil || err.Error() != tc.expected {
+				t.Errorf("Expected error '%s', got valid: %v, error: %v", tc.expected, valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package main
+
+// raceEnabled reports whether the tests are run with the race detector,
+// which changes the allocations made by the policy.
+const raceEnabled = true
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	testRequestUID = "1299d386-525b-4032-98ae-1949f69f9cfc"
+	testUsername   = "kubernetes-admin"
+)
+
+// requestBuilder builds complete validation requests for the tests. All the
+// fields of the admission request are filled with sensible defaults, derived
+// from the object whenever possible, and can be changed fluently:
+//
+//	response := newRequest(t, pod).User("alice", "dev").Validate(ev)
+type requestBuilder struct {
+	t        testing.TB
+	request  kubewarden_protocol.KubernetesAdmissionRequest
+	settings interface{}
+}
+
+// rawObject returns the JSON representation of the given object. Strings and
+// byte slices are considered to be JSON already.
+func rawObject(t testing.TB, object interface{}) json.RawMessage {
+	t.Helper()
+
+	switch o := object.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.RawMessage(o)
+	case []byte:
+		return json.RawMessage(o)
+	default:
+		raw, err := json.Marshal(object)
+		if err != nil {
+			t.Fatalf("Cannot serialize object: %+v", err)
+		}
+		return raw
+	}
+}
+
+// resourceName returns the plural lower case name of the resource of the
+// given kind, for example "ingresses" for "Ingress".
+func resourceName(kind string) string {
+	resource := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(resource, "s"):
+		return resource + "es"
+	case strings.HasSuffix(resource, "y"):
+		return strings.TrimSuffix(resource, "y") + "ies"
+	default:
+		return resource + "s"
+	}
+}
+
+// newRequest creates a CREATE request of the given object, made by a cluster
+// administrator. The kind, the name and the namespace of the request are the
+// ones of the object, a Pod is assumed when the object has no kind.
+func newRequest(t testing.TB, object interface{}) *requestBuilder {
+	t.Helper()
+
+	raw := rawObject(t, object)
+	header := struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &header); err != nil {
+			t.Fatalf("Cannot decode object: %+v", err)
+		}
+	}
+
+	b := &requestBuilder{
+		t: t,
+		request: kubewarden_protocol.KubernetesAdmissionRequest{
+			Uid:       testRequestUID,
+			Name:      header.Metadata.Name,
+			Namespace: header.Metadata.Namespace,
+			Operation: operationCreate,
+			UserInfo: kubewarden_protocol.UserInfo{
+				Username: testUsername,
+				Groups:   []string{"system:masters", "system:authenticated"},
+			},
+			Object: raw,
+		},
+		settings: Settings{DeniedNames: []DenyEntry{}},
+	}
+
+	group, version := "", "v1"
+	if header.APIVersion != "" {
+		if g, v, found := strings.Cut(header.APIVersion, "/"); found {
+			group, version = g, v
+		} else {
+			version = header.APIVersion
+		}
+	}
+	kind := header.Kind
+	if kind == "" {
+		kind = "Pod"
+	}
+
+	return b.Kind(group, version, kind)
+}
+
+// testPod creates a Pod with the given name and namespace, the object most
+// tests validate.
+func testPod(name, namespace string) corev1.Pod {
+	return corev1.Pod{Metadata: &metav1.ObjectMeta{Name: name, Namespace: namespace}}
+}
+
+// updateRequest creates an UPDATE request changing oldObject into newObject.
+func updateRequest(t testing.TB, oldObject, newObject interface{}) *requestBuilder {
+	t.Helper()
+
+	return newRequest(t, newObject).Operation(operationUpdate).OldObject(oldObject)
+}
+
+// deleteRequest creates a DELETE request of the given object, which is
+// carried only by the OldObject field, as done by the API server.
+func deleteRequest(t testing.TB, object interface{}) *requestBuilder {
+	t.Helper()
+
+	b := newRequest(t, object).Operation(operationDelete).OldObject(object)
+	b.request.Object = nil
+
+	return b
+}
+
+// Kind sets the kind and the resource of the request.
+func (b *requestBuilder) Kind(group, version, kind string) *requestBuilder {
+	gvk := kubewarden_protocol.GroupVersionKind{Group: group, Version: version, Kind: kind}
+	resource := kubewarden_protocol.GroupVersionKind{Group: group, Version: version, Kind: resourceName(kind)}
+
+	b.request.Kind = gvk
+	b.request.RequestKind = gvk
+	b.request.Resource = kubewarden_protocol.GroupVersionResource(resource)
+	b.request.RequestResource = resource
+
+	return b
+}
+
+// Operation sets the operation of the request.
+func (b *requestBuilder) Operation(operation string) *requestBuilder {
+	b.request.Operation = operation
+	return b
+}
+
+// Name sets the name of the request.
+func (b *requestBuilder) Name(name string) *requestBuilder {
+	b.request.Name = name
+	return b
+}
+
+// Namespace sets the namespace of the request.
+func (b *requestBuilder) Namespace(namespace string) *requestBuilder {
+	b.request.Namespace = namespace
+	return b
+}
+
+// User sets the user making the request.
+func (b *requestBuilder) User(username string, groups ...string) *requestBuilder {
+	b.request.UserInfo = kubewarden_protocol.UserInfo{Username: username, Groups: groups}
+	return b
+}
+
+// OldObject sets the object being replaced or removed.
+func (b *requestBuilder) OldObject(object interface{}) *requestBuilder {
+	b.request.OldObject = rawObject(b.t, object)
+	return b
+}
+
+// SubResource sets the subresource being requested, for example "status".
+func (b *requestBuilder) SubResource(subResource string) *requestBuilder {
+	b.request.SubResource = subResource
+	b.request.RequestSubResource = subResource
+	return b
+}
+
+// DryRun marks the request as a dry run.
+func (b *requestBuilder) DryRun() *requestBuilder {
+	b.request.DryRun = true
+	return b
+}
+
+// Settings sets the settings of the policy, which default to an empty deny
+// list.
+func (b *requestBuilder) Settings(settings interface{}) *requestBuilder {
+	b.settings = settings
+	return b
+}
+
+// Build returns the validation request.
+func (b *requestBuilder) Build() kubewarden_protocol.ValidationRequest {
+	b.t.Helper()
+
+	return kubewarden_protocol.ValidationRequest{
+		Request:  b.request,
+		Settings: rawObject(b.t, b.settings),
+	}
+}
+
+// Payload returns the validation request, serialized as the payload of the
+// `validate` function.
+func (b *requestBuilder) Payload() []byte {
+	b.t.Helper()
+
+	validationRequest := b.Build()
+	payload, err := json.Marshal(&validationRequest)
+	if err != nil {
+		b.t.Fatalf("Cannot serialize validation request: %+v", err)
+	}
+
+	return payload
+}
+
+// Validate evaluates the request with the given evaluator and returns the
+// decoded response.
+func (b *requestBuilder) Validate(ev *evaluator) kubewarden_protocol.ValidationResponse {
+	b.t.Helper()
+
+	responsePayload, err := ev.validate(b.Payload())
+	if err != nil {
+		b.t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	var response kubewarden_protocol.ValidationResponse
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		b.t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	return response
+}
+
+func TestRequestBuilderDefaults(t *testing.T) {
+	request := newRequest(t, ingressObject).Build().Request
+
+	if request.Operation != operationCreate || request.Uid != testRequestUID {
+		t.Errorf("Unexpected operation or UID: %+v", request)
+	}
+	if request.Name != "shop" || request.Namespace != "shop" {
+		t.Errorf("Name and namespace should be the ones of the object: %+v", request)
+	}
+	expectedKind := kubewarden_protocol.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}
+	if request.Kind != expectedKind || request.RequestKind != expectedKind {
+		t.Errorf("Unexpected kind: %+v", request.Kind)
+	}
+	if request.Resource.Kind != "ingresses" || request.Resource.Group != "networking.k8s.io" {
+		t.Errorf("Unexpected resource: %+v", request.Resource)
+	}
+	if request.UserInfo.Username != testUsername || len(request.UserInfo.Groups) != 2 {
+		t.Errorf("Unexpected user: %+v", request.UserInfo)
+	}
+	if len(request.OldObject) != 0 || request.DryRun || request.SubResource != "" {
+		t.Errorf("Unexpected optional fields: %+v", request)
+	}
+
+	podRequest := newRequest(t, `{"metadata": {"name": "test-pod"}}`).Build().Request
+	if podRequest.Kind.Kind != "Pod" || podRequest.Kind.Version != "v1" || podRequest.Resource.Kind != "pods" {
+		t.Errorf("A Pod should be assumed for objects without a kind: %+v", podRequest.Kind)
+	}
+}
+
+func TestRequestBuilderOverrides(t *testing.T) {
+	request := newRequest(t, `{"metadata": {"name": "test-pod", "namespace": "default"}}`).
+		Name("other").
+		Namespace("shop").
+		User("alice", "dev").
+		SubResource("status").
+		DryRun().
+		Build().Request
+
+	if request.Name != "other" || request.Namespace != "shop" {
+		t.Errorf("Unexpected name or namespace: %+v", request)
+	}
+	if request.UserInfo.Username != "alice" || len(request.UserInfo.Groups) != 1 || request.UserInfo.Groups[0] != "dev" {
+		t.Errorf("Unexpected user: %+v", request.UserInfo)
+	}
+	if request.SubResource != "status" || request.RequestSubResource != "status" || !request.DryRun {
+		t.Errorf("Unexpected optional fields: %+v", request)
+	}
+}
+
+func TestRequestBuilderUpdateAndDelete(t *testing.T) {
+	oldPod := `{"metadata": {"name": "test-pod"}, "spec": {"containers": [{"name": "app", "image": "app:1"}]}}`
+	newPod := `{"metadata": {"name": "test-pod"}, "spec": {"containers": [{"name": "app", "image": "app:2"}]}}`
+
+	update := updateRequest(t, oldPod, newPod).Build().Request
+	if update.Operation != operationUpdate || string(update.OldObject) != oldPod || string(update.Object) != newPod {
+		t.Errorf("Unexpected UPDATE request: %+v", update)
+	}
+
+	deletion := deleteRequest(t, oldPod).Build().Request
+	if deletion.Operation != operationDelete || string(deletion.OldObject) != oldPod || len(deletion.Object) != 0 {
+		t.Errorf("Unexpected DELETE request: %+v", deletion)
+	}
+	if deletion.Name != "test-pod" {
+		t.Errorf("The name should be the one of the removed object: %+v", deletion)
+	}
+}
+
+func TestDeleteOfDeniedNameIsAccepted(t *testing.T) {
+	pod := `{"metadata": {"name": "test-pod", "namespace": "default"}}`
+
+	response := deleteRequest(t, pod).
+		Settings(Settings{DeniedNames: denyEntries("test-pod")}).
+		Validate(newEvaluator())
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+}
+
+func TestUpdateOfDeniedNameIsAccepted(t *testing.T) {
+	// the name of an existing object cannot change, rejecting the update
+	// would only block label changes and finalizer removals
+	response := updateRequest(t,
+		`{"metadata": {"name": "test-pod", "namespace": "default", "finalizers": ["example.com/cleanup"]}}`,
+		`{"metadata": {"name": "test-pod", "namespace": "default", "labels": {"app": "shop"}}}`).
+		Settings(Settings{DeniedNames: denyEntries("test-pod")}).
+		Validate(newEvaluator())
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+}
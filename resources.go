@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/kubernetes"
+)
+
+// resourceLookup is the outcome of a `get_resource` call made to the host.
+type resourceLookup struct {
+	payload []byte
+	err     error
+}
+
+// getResource fetches a Kubernetes resource through the Kubernetes
+// capability of the Kubewarden host.
+//
+// The outcome of each lookup is cached for the lifetime of the evaluator,
+// which is a single evaluation: looking up the same resource multiple times
+// results in a single host call.
+func (ev *evaluator) getResource(apiVersion, kind, namespace, name string) ([]byte, error) {
+	cacheKey := fmt.Sprintf("%s/%s/%s/%s", apiVersion, kind, namespace, name)
+	if lookup, found := ev.resourceCache[cacheKey]; found {
+		return lookup.payload, lookup.err
+	}
+
+	request := kubernetes.GetResourceRequest{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       name,
+	}
+	if namespace != "" {
+		request.Namespace = &namespace
+	}
+
+	lookup := resourceLookup{}
+	lookup.payload, lookup.err = kubernetes.GetResource(ev.capabilitiesHost(), request)
+
+	if ev.resourceCache == nil {
+		ev.resourceCache = map[string]resourceLookup{}
+	}
+	ev.resourceCache[cacheKey] = lookup
+
+	return lookup.payload, lookup.err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourceLookupsAreCachedWithinAnEvaluation(t *testing.T) {
+	host := newFakeHostClient()
+	host.AddResource(t, "v1", "Namespace", "", "default", corev1.Namespace{
+		Metadata: &metav1.ObjectMeta{Name: "default"},
+	})
+
+	ev := newEvaluatorWithHost(host)
+	for range 3 {
+		if _, err := ev.getResource("v1", "Namespace", "", "default"); err != nil {
+			t.Fatalf("Unexpected error: %+v", err)
+		}
+	}
+	// failed lookups are cached too
+	for range 2 {
+		if _, err := ev.getResource("v1", "Namespace", "", "missing"); err == nil {
+			t.Fatalf("Expected an error")
+		}
+	}
+
+	if calls := host.Calls("kubernetes", "get_resource"); len(calls) != 2 {
+		t.Errorf("Expected 2 host calls, got %d", len(calls))
+	}
+
+	// every evaluation starts with an empty cache
+	if _, err := newEvaluatorWithHost(host).getResource("v1", "Namespace", "", "default"); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if calls := host.Calls("kubernetes", "get_resource"); len(calls) != 3 {
+		t.Errorf("Expected 3 host calls, got %d", len(calls))
+	}
+}
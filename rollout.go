@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+const (
+	maxRolloutPercentage = 100
+	rolloutKeyNamespace  = "namespace"
+	rolloutKeyName       = "name"
+)
+
+// rolloutBucket deterministically maps the given key to a bucket inside of
+// the [0, 100) range.
+//
+// The 32-bit FNV-1a hash is used because it is fully specified and does not
+// depend on any random seed: the same key always lands in the same bucket,
+// regardless of the policy being built with TinyGo or with the official Go
+// compiler.
+func rolloutBucket(key string) uint32 {
+	hash := fnv.New32a()
+	// writing to a hash never fails
+	_, _ = hash.Write([]byte(key))
+
+	return hash.Sum32() % maxRolloutPercentage
+}
+
+// validateRollout ensures the rollout settings are usable.
+func (s *Settings) validateRollout() error {
+	if s.RolloutPercentage != nil && (*s.RolloutPercentage < 0 || *s.RolloutPercentage > maxRolloutPercentage) {
+		return fmt.Errorf("rollout_percentage must be between 0 and %d", maxRolloutPercentage)
+	}
+	for namespace, percentage := range s.NamespaceRolloutPercentages {
+		if percentage < 0 || percentage > maxRolloutPercentage {
+			return fmt.Errorf("namespace_rollout_percentages[%s] must be between 0 and %d", namespace, maxRolloutPercentage)
+		}
+	}
+
+	switch s.RolloutKey {
+	case "", rolloutKeyNamespace, rolloutKeyName:
+		return nil
+	default:
+		return fmt.Errorf("rollout_key must be either '%s' or '%s'", rolloutKeyNamespace, rolloutKeyName)
+	}
+}
+
+// RolloutPercentageFor returns the percentage of violations that are
+// enforced inside of the given namespace.
+func (s *Settings) RolloutPercentageFor(namespace string) int {
+	if percentage, found := s.NamespaceRolloutPercentages[namespace]; found {
+		return percentage
+	}
+	if s.RolloutPercentage != nil {
+		return *s.RolloutPercentage
+	}
+
+	return maxRolloutPercentage
+}
+
+// IsEnforced returns true when a violation of the deny rules by the given
+// object has to be enforced. Otherwise the violation must only be logged.
+func (s *Settings) IsEnforced(namespace, name string) bool {
+	percentage := s.RolloutPercentageFor(namespace)
+	if percentage >= maxRolloutPercentage {
+		return true
+	}
+	if percentage <= 0 {
+		return false
+	}
+
+	key := namespace
+	if s.RolloutKey == rolloutKeyName {
+		key = name
+	}
+
+	return rolloutBucket(key) < uint32(percentage) //nolint:gosec // percentage is within the [1, 99] range
+}
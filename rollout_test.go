@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// The buckets are derived from the published FNV-1a test vectors. Any build of
+// the policy, TinyGo or native, must place these keys inside of the same
+// buckets, otherwise the rollout would change depending on the compiler.
+func TestRolloutBucketIsStable(t *testing.T) {
+	cases := []struct {
+		key    string
+		hash   uint32
+		bucket uint32
+	}{
+		{"", 0x811c9dc5, 61},
+		{"a", 0xe40c292c, 20},
+		{"foobar", 0xbf9cf968, 20},
+		{"default", 0x933b5bde, 94},
+		{"team-a", 0x26db73d6, 46},
+		{"team-b", 0x25db7243, 27},
+	}
+
+	for _, tc := range cases {
+		if tc.hash%maxRolloutPercentage != tc.bucket {
+			t.Fatalf("Broken test vector for %q", tc.key)
+		}
+		if got := rolloutBucket(tc.key); got != tc.bucket {
+			t.Errorf("Key %q: got bucket %d instead of %d", tc.key, got, tc.bucket)
+		}
+	}
+}
+
+func TestIsEnforced(t *testing.T) {
+	settings := Settings{
+		RolloutPercentage: intPtr(30),
+		NamespaceRolloutPercentages: map[string]int{
+			"kube-system": 0,
+			"default":     100,
+		},
+	}
+
+	cases := []struct {
+		namespace string
+		enforced  bool
+	}{
+		{"team-a", false}, // bucket 46
+		{"team-b", true},  // bucket 27
+		{"kube-system", false},
+		{"default", true},
+	}
+
+	for _, tc := range cases {
+		if got := settings.IsEnforced(tc.namespace, "test-pod"); got != tc.enforced {
+			t.Errorf("Namespace %s: got enforced=%v instead of %v", tc.namespace, got, tc.enforced)
+		}
+	}
+
+	byName := Settings{RolloutPercentage: intPtr(20), RolloutKey: rolloutKeyName}
+	if !byName.IsEnforced("team-a", "test-pod") { // bucket 19
+		t.Errorf("Violation should be enforced")
+	}
+	if byName.IsEnforced("team-a", "foobar") { // bucket 20
+		t.Errorf("Violation should not be enforced")
+	}
+
+	if !(&Settings{}).IsEnforced("team-a", "test-pod") {
+		t.Errorf("Violations must be enforced by default")
+	}
+}
+
+func TestInvalidRolloutSettings(t *testing.T) {
+	cases := map[string]Settings{
+		"negative percentage":           {RolloutPercentage: intPtr(-1)},
+		"percentage too high":           {RolloutPercentage: intPtr(101)},
+		"namespace percentage too high": {NamespaceRolloutPercentages: map[string]int{"default": 200}},
+		"unknown key":                   {RolloutKey: "uid"},
+	}
+
+	for name, settings := range cases {
+		t.Run(name, func(t *testing.T) {
+			valid, err := settings.Valid()
+			if valid || err == nil {
+				t.Errorf("Expected settings to be invalid")
+			}
+		})
+	}
+}
+
+func TestDenyListViolationIsOnlyLoggedOutsideOfRollout(t *testing.T) {
+	settings := Settings{
+		DeniedNames:                 denyEntries("test-pod"),
+		RolloutPercentage:           intPtr(50),
+		NamespaceRolloutPercentages: map[string]int{"team-c": 0},
+	}
+
+	cases := []struct {
+		namespace string
+		accepted  bool
+	}{
+		{"team-a", false}, // bucket 46
+		{"default", true}, // bucket 94
+		{"team-c", true},
+	}
+
+	for _, tc := range cases {
+		response := newRequest(t, testPod("test-pod", tc.namespace)).Settings(&settings).Validate(newEvaluator())
+		if response.Accepted != tc.accepted {
+			t.Errorf("Namespace %s: got accepted=%v instead of %v", tc.namespace, response.Accepted, tc.accepted)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// labelRequirement is a single requirement of a label selector.
+type labelRequirement struct {
+	key      string
+	value    string
+	operator string
+}
+
+const (
+	selectorEquals       = "="
+	selectorNotEquals    = "!="
+	selectorExists       = "exists"
+	selectorDoesNotExist = "!"
+)
+
+// LabelSelector is an equality-based Kubernetes label selector, for example
+// "env=prod,tier!=frontend,team,!legacy".
+type LabelSelector struct {
+	requirements []labelRequirement
+}
+
+// ParseLabelSelector parses the textual representation of an equality-based
+// label selector. Set-based requirements, like "env in (prod)", are not
+// supported.
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	parsed := LabelSelector{}
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		requirement := labelRequirement{}
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2) //nolint:mnd // key and value
+			requirement = labelRequirement{key: parts[0], value: parts[1], operator: selectorNotEquals}
+		case strings.Contains(term, "=="):
+			parts := strings.SplitN(term, "==", 2) //nolint:mnd // key and value
+			requirement = labelRequirement{key: parts[0], value: parts[1], operator: selectorEquals}
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2) //nolint:mnd // key and value
+			requirement = labelRequirement{key: parts[0], value: parts[1], operator: selectorEquals}
+		case strings.HasPrefix(term, "!"):
+			requirement = labelRequirement{key: term[1:], operator: selectorDoesNotExist}
+		default:
+			requirement = labelRequirement{key: term, operator: selectorExists}
+		}
+
+		requirement.key = strings.TrimSpace(requirement.key)
+		requirement.value = strings.TrimSpace(requirement.value)
+		if requirement.key == "" || strings.ContainsAny(requirement.key, " ()") ||
+			strings.ContainsAny(requirement.value, " =!()") {
+			return LabelSelector{}, fmt.Errorf("invalid label selector term %q", term)
+		}
+		parsed.requirements = append(parsed.requirements, requirement)
+	}
+
+	return parsed, nil
+}
+
+// Matches returns true when the given labels satisfy all the requirements of
+// the selector. An empty selector matches everything.
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	for _, requirement := range s.requirements {
+		value, found := labels[requirement.key]
+		switch requirement.operator {
+		case selectorEquals:
+			if !found || value != requirement.value {
+				return false
+			}
+		case selectorNotEquals:
+			if found && value == requirement.value {
+				return false
+			}
+		case selectorExists:
+			if !found {
+				return false
+			}
+		case selectorDoesNotExist:
+			if found {
+				return false
+			}
+		}
+	}
+
+	return true
+}
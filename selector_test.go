@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{
+		"env":  "prod",
+		"team": "payments",
+	}
+
+	cases := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env==prod", true},
+		{"env = prod , team", true},
+		{"env=staging", false},
+		{"env!=staging", true},
+		{"env!=prod", false},
+		{"tier!=frontend", true},
+		{"team", true},
+		{"tier", false},
+		{"!legacy", true},
+		{"!team", false},
+		{"env=prod,team=payments,!legacy", true},
+		{"env=prod,team=search", false},
+	}
+
+	for _, tc := range cases {
+		selector, err := ParseLabelSelector(tc.selector)
+		if err != nil {
+			t.Fatalf("Selector %q: unexpected error %+v", tc.selector, err)
+		}
+		if got := selector.Matches(labels); got != tc.matches {
+			t.Errorf("Selector %q: got %v instead of %v", tc.selector, got, tc.matches)
+		}
+	}
+}
+
+func TestInvalidLabelSelectors(t *testing.T) {
+	for _, selector := range []string{"=prod", "env in (prod)", "env=prod=1", "!"} {
+		if _, err := ParseLabelSelector(selector); err == nil {
+			t.Errorf("Selector %q: expected an error", selector)
+		}
+	}
+}
@@ -3,14 +3,55 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
+	onelog "github.com/francoispqt/onelog"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
 	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
 )
 
 // Settings is the structure that describes the policy settings.
 type Settings struct {
-	DeniedNames []string `json:"denied_names"`
+	DeniedNames   []DenyEntry         `json:"denied_names"`
+	FreezeWindows *FreezeSettings     `json:"freeze_windows,omitempty"`
+	BreakGlass    *BreakGlassSettings `json:"break_glass,omitempty"`
+	// RolloutPercentage is the percentage of deny list violations that are
+	// enforced, the other ones are only logged. Defaults to 100.
+	RolloutPercentage *int `json:"rollout_percentage,omitempty"`
+	// NamespaceRolloutPercentages overrides RolloutPercentage for specific
+	// namespaces.
+	NamespaceRolloutPercentages map[string]int `json:"namespace_rollout_percentages,omitempty"`
+	// RolloutKey is what decides whether a violation is enforced: either the
+	// "namespace" (the default) or the "name" of the object.
+	RolloutKey string `json:"rollout_key,omitempty"`
+	// ContextAware enables the features that query the Kubernetes cluster
+	// through the Kubewarden host.
+	ContextAware bool `json:"context_aware,omitempty"`
+	// NamespaceSelector restricts the deny list to the namespaces whose
+	// labels match this equality-based selector, for example "env=prod".
+	// Requires ContextAware.
+	NamespaceSelector string `json:"namespace_selector,omitempty"`
+	// DenyListSource points to a ConfigMap with additional deny entries.
+	// Requires ContextAware.
+	DenyListSource *DenyListSource `json:"deny_list_source,omitempty"`
+	// NameCollision rejects objects whose name is already used inside of
+	// another namespace. Requires ContextAware.
+	NameCollision *NameCollisionSettings `json:"name_collision,omitempty"`
+	// AccessReviewExemption lets the users authorized to perform an action
+	// bypass the deny rules. Requires ContextAware.
+	AccessReviewExemption *AccessReviewExemption `json:"access_review_exemption,omitempty"`
+	// ImageSignatures requires the images of the Pods to be signed with
+	// sigstore.
+	ImageSignatures *ImageSignatureSettings `json:"image_signatures,omitempty"`
+	// DigestPinning mutates the objects to pin their image tags to digests.
+	DigestPinning *DigestPinningSettings `json:"digest_pinning,omitempty"`
+	// TLSSecrets validates the certificates of `kubernetes.io/tls` Secrets.
+	TLSSecrets *TLSSecretSettings `json:"tls_secrets,omitempty"`
+	// IngressHosts restricts the hostnames of Ingress objects.
+	IngressHosts *IngressHostSettings `json:"ingress_hosts,omitempty"`
+	// NamespaceOverrides lets namespaces override some of the settings
+	// through an annotation. Requires ContextAware.
+	NamespaceOverrides *NamespaceOverrideSettings `json:"namespace_overrides,omitempty"`
 }
 
 func NewSettingsFromValidationReq(validationReq *kubewarden_protocol.ValidationRequest) (Settings, error) {
@@ -19,24 +60,166 @@ func NewSettingsFromValidationReq(validationReq *kubewarden_protocol.ValidationR
 	return settings, err
 }
 
-// Valid is the structure that informs if the policy settings are valid. No
-// special checks have to be done.
+// Valid is the structure that informs if the policy settings are valid. Every
+// deny entry must have a name and, when it is time-bounded, must not expire
+// before becoming effective. All the other sections must be valid too, and
+// the ones that query the cluster require the context-aware mode.
 func (s *Settings) Valid() (bool, error) {
+	if err := s.validateDenyEntries(); err != nil {
+		return false, err
+	}
+
+	if err := s.validateOptionalSections(); err != nil {
+		return false, err
+	}
+
+	if err := s.validateRollout(); err != nil {
+		return false, err
+	}
+
+	if err := s.validateContextAwareFeatures(); err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
-func (s *Settings) IsNameDenied(name string) bool {
-	for _, deniedName := range s.DeniedNames {
-		if deniedName == name {
-			return true
+func (s *Settings) validateDenyEntries() error {
+	for i, entry := range s.DeniedNames {
+		if entry.Name == "" {
+			return fmt.Errorf("denied_names[%d]: name cannot be empty", i)
+		}
+		if entry.NotBefore != nil && entry.ExpiresAt != nil && !entry.NotBefore.Before(*entry.ExpiresAt) {
+			return fmt.Errorf("denied_names[%d]: entry for '%s' must have 'not_before' before 'expires_at'", i, entry.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateOptionalSections validates the sections of the settings that are
+// configured.
+func (s *Settings) validateOptionalSections() error {
+	sections := []struct {
+		enabled  bool
+		validate func() error
+	}{
+		{s.FreezeWindows != nil, func() error { return s.FreezeWindows.Validate() }},
+		{s.BreakGlass != nil, func() error { return s.BreakGlass.Validate() }},
+		{s.ImageSignatures != nil, func() error { return s.ImageSignatures.Validate() }},
+		{s.DigestPinning != nil, func() error { return s.DigestPinning.Validate() }},
+		{s.TLSSecrets != nil, func() error { return s.TLSSecrets.Validate() }},
+		{s.IngressHosts != nil, func() error { return s.IngressHosts.Validate() }},
+	}
+
+	for _, section := range sections {
+		if !section.enabled {
+			continue
+		}
+		if err := section.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateContextAwareFeatures ensures the features that query the cluster
+// are enabled only in context-aware mode, and that they are valid.
+func (s *Settings) validateContextAwareFeatures() error {
+	features := []struct {
+		name     string
+		enabled  bool
+		validate func() error
+	}{
+		{
+			name:    "namespace_selector",
+			enabled: s.NamespaceSelector != "",
+			validate: func() error {
+				if _, err := ParseLabelSelector(s.NamespaceSelector); err != nil {
+					return fmt.Errorf("namespace_selector: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "deny_list_source",
+			enabled:  s.DenyListSource != nil,
+			validate: func() error { return s.DenyListSource.Validate() },
+		},
+		{
+			name:     "name_collision",
+			enabled:  s.NameCollision != nil,
+			validate: func() error { return s.NameCollision.Validate() },
+		},
+		{
+			name:     "access_review_exemption",
+			enabled:  s.AccessReviewExemption != nil,
+			validate: func() error { return s.AccessReviewExemption.Validate() },
+		},
+		{
+			name:     "namespace_overrides",
+			enabled:  s.NamespaceOverrides != nil,
+			validate: func() error { return s.NamespaceOverrides.Validate() },
+		},
+	}
+
+	for _, feature := range features {
+		if !feature.enabled {
+			continue
+		}
+		if !s.ContextAware {
+			return fmt.Errorf("%s requires context_aware to be enabled", feature.name)
+		}
+		if err := feature.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Settings) IsNameDenied(name string, now time.Time) bool {
+	return s.FindDenyEntry(name, now) != nil
+}
+
+// FindDenyEntry returns the deny entry matching the given name that is active
+// at the given time, or nil when the name is not denied.
+func (s *Settings) FindDenyEntry(name string, now time.Time) *DenyEntry {
+	for i := range s.DeniedNames {
+		if s.DeniedNames[i].Name == name && s.DeniedNames[i].ActiveAt(now) {
+			return &s.DeniedNames[i]
 		}
 	}
 
-	return false
+	return nil
 }
 
-func validateSettings(payload []byte) ([]byte, error) {
-	logger.Info("validating settings")
+// ExpiredDenyEntries returns the deny entries that are expired at the given
+// time.
+func (s *Settings) ExpiredDenyEntries(now time.Time) []DenyEntry {
+	expired := []DenyEntry{}
+	for _, entry := range s.DeniedNames {
+		if entry.ExpiredAt(now) {
+			expired = append(expired, entry)
+		}
+	}
+
+	return expired
+}
+
+// DeniedNamesList returns the names on the deny list, without their metadata.
+func (s *Settings) DeniedNamesList() []string {
+	names := make([]string, 0, len(s.DeniedNames))
+	for _, entry := range s.DeniedNames {
+		names = append(names, entry.Name)
+	}
+
+	return names
+}
+
+func (ev *evaluator) validateSettings(payload []byte) ([]byte, error) {
+	ev.logger.Info("validating settings")
 
 	settings := Settings{}
 	err := json.Unmarshal(payload, &settings)
@@ -48,10 +231,19 @@ func validateSettings(payload []byte) ([]byte, error) {
 	if err != nil {
 		return kubewarden.RejectSettings(kubewarden.Message(fmt.Sprintf("Provided settings are not valid: %v", err)))
 	}
-	if valid {
-		return kubewarden.AcceptSettings()
+	if !valid {
+		ev.logger.Warn("rejecting settings")
+		return kubewarden.RejectSettings(kubewarden.Message("Provided settings are not valid"))
+	}
+
+	// expired entries are only reported: the settings were valid when they
+	// were written, rejecting them would take down the whole policy
+	for _, entry := range settings.ExpiredDenyEntries(ev.clock()) {
+		ev.logger.WarnWithFields("deny entry is expired, it can be removed", func(e onelog.Entry) {
+			e.String("name", entry.Name)
+			e.String("expires_at", entry.ExpiresAt.Format(time.RFC3339))
+		})
 	}
 
-	logger.Warn("rejecting settings")
-	return kubewarden.RejectSettings(kubewarden.Message("Provided settings are not valid"))
+	return kubewarden.AcceptSettings()
 }
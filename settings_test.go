@@ -3,6 +3,9 @@ package main
 import (
 	"encoding/json"
 	"testing"
+	"time"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
 )
 
 func TestParsingSettingsWithNoValueProvided(t *testing.T) {
@@ -27,14 +30,83 @@ func TestParsingSettingsWithNoValueProvided(t *testing.T) {
 
 func TestIsNameDenied(t *testing.T) {
 	settings := Settings{
-		DeniedNames: []string{"bob"},
+		DeniedNames: denyEntries("bob"),
 	}
 
-	if !settings.IsNameDenied("bob") {
+	if !settings.IsNameDenied("bob", time.Now()) {
 		t.Errorf("name should be denied")
 	}
 
-	if settings.IsNameDenied("alice") {
+	if settings.IsNameDenied("alice", time.Now()) {
 		t.Errorf("name should not be denied")
 	}
 }
+
+func TestDenyEntryWithInvertedTimeRangeIsNotValid(t *testing.T) {
+	notBefore := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, expiresAt := range []time.Time{notBefore.AddDate(0, -1, 0), notBefore} {
+		settings := Settings{
+			DeniedNames: []DenyEntry{
+				{Name: "bob", NotBefore: &notBefore, ExpiresAt: &expiresAt},
+			},
+		}
+
+		valid, err := settings.Valid()
+		if valid {
+			t.Errorf("expires_at %s: settings are reported as valid", expiresAt)
+		}
+		expected := "denied_names[0]: entry for 'bob' must have 'not_before' before 'expires_at'"
+		if err == nil || err.Error() != expected {
+			t.Errorf("expires_at %s: expected error '%s', got %v", expiresAt, expected, err)
+		}
+	}
+}
+
+func TestIsNameDeniedHonorsTimeBounds(t *testing.T) {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	settings := Settings{
+		DeniedNames: []DenyEntry{
+			{Name: "bob", NotBefore: &notBefore, ExpiresAt: &expiresAt},
+		},
+	}
+
+	if settings.IsNameDenied("bob", notBefore.Add(-time.Hour)) {
+		t.Errorf("name should not be denied before the entry is effective")
+	}
+	if !settings.IsNameDenied("bob", notBefore.Add(time.Hour)) {
+		t.Errorf("name should be denied while the entry is effective")
+	}
+	if settings.IsNameDenied("bob", expiresAt) {
+		t.Errorf("name should not be denied once the entry expired")
+	}
+}
+
+func TestValidateSettingsWarnsAboutExpiredEntries(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	ev := newEvaluator()
+	ev.clock = fixedClock(now)
+	logs := newLogSink(t)
+	ev.logger = logs.Logger()
+
+	responsePayload, err := ev.validateSettings([]byte(`{
+		"denied_names": [{"name": "bob", "expires_at": "2026-02-01T00:00:00Z"}]
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	var response kubewarden_protocol.SettingsValidationResponse
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	if !response.Valid {
+		t.Fatalf("Unexpected rejection of the settings: %s", *response.Message)
+	}
+	entry := logs.Find("warn", "deny entry is expired, it can be removed")
+	if entry.String("name") != "bob" || entry.String("expires_at") != "2026-02-01T00:00:00Z" {
+		t.Errorf("Unexpected log entry %+v", entry)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	onelog "github.com/francoispqt/onelog"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci/verify_v2"
+)
+
+// subResourceEphemeralContainers is the subresource used to add ephemeral
+// containers to a running Pod.
+const subResourceEphemeralContainers = "ephemeralcontainers"
+
+// ImageSignatureSettings requires all the images of a Pod to be signed with
+// sigstore. The signatures are verified by the Kubewarden host.
+//
+// When both public keys and keyless identities are provided, an image must
+// satisfy both of them.
+type ImageSignatureSettings struct {
+	// PubKeys are PEM encoded public keys that must have signed the image.
+	PubKeys []string `json:"pub_keys,omitempty"`
+	// Keyless are the OIDC issuer and subject pairs of the identities that
+	// must have signed the image.
+	Keyless []oci.KeylessInfo `json:"keyless,omitempty"`
+	// Annotations that must have been provided by the signers.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Validate ensures at least one signer is configured.
+func (s *ImageSignatureSettings) Validate() error {
+	if len(s.PubKeys) == 0 && len(s.Keyless) == 0 {
+		return errors.New("image_signatures: at least one of pub_keys or keyless must be provided")
+	}
+	for i, keyless := range s.Keyless {
+		if keyless.Issuer == "" || keyless.Subject == "" {
+			return fmt.Errorf("image_signatures.keyless[%d]: issuer and subject must be provided", i)
+		}
+	}
+
+	return nil
+}
+
+// podImages returns the images used by the containers, the init containers
+// and the ephemeral containers of the given Pod spec, without duplicates.
+func podImages(spec *corev1.PodSpec) []string {
+	if spec == nil {
+		return []string{}
+	}
+
+	images := []string{}
+	seen := map[string]bool{}
+	add := func(image string) {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	for _, container := range spec.Containers {
+		add(container.Image)
+	}
+	for _, container := range spec.InitContainers {
+		add(container.Image)
+	}
+	for _, container := range spec.EphemeralContainers {
+		add(container.Image)
+	}
+
+	return images
+}
+
+// verifyImage returns nil when the image is signed by all the configured
+// signers.
+func (ev *evaluator) verifyImage(signatures *ImageSignatureSettings, image string) error {
+	if len(signatures.PubKeys) > 0 {
+		response, err := verify_v2.VerifyPubKeysImage(ev.capabilitiesHost(), image, signatures.PubKeys, signatures.Annotations)
+		if err != nil {
+			return err
+		}
+		if !response.IsTrusted {
+			return errors.New("not signed by the configured public keys")
+		}
+	}
+
+	if len(signatures.Keyless) > 0 {
+		response, err := verify_v2.VerifyKeylessExactMatch(ev.capabilitiesHost(), image, signatures.Keyless, signatures.Annotations)
+		if err != nil {
+			return err
+		}
+		if !response.IsTrusted {
+			return errors.New("not signed by the configured keyless identities")
+		}
+	}
+
+	return nil
+}
+
+// imageSignatureViolation verifies the signatures of all the images of the
+// given Pod spec and returns the violation message, or an empty string when all the
+// images are signed. Images whose signatures cannot be verified are reported
+// as unsigned.
+func (ev *evaluator) imageSignatureViolation(settings *Settings, metadata *metav1.ObjectMeta, spec *corev1.PodSpec) string {
+	if settings.ImageSignatures == nil {
+		return ""
+	}
+
+	unsigned := []string{}
+	for _, image := range podImages(spec) {
+		if err := ev.verifyImage(settings.ImageSignatures, image); err != nil {
+			ev.logger.InfoWithFields("image signature verification failed", func(e onelog.Entry) {
+				e.String("name", metadata.Name)
+				e.String("image", image)
+				e.String("error", err.Error())
+			})
+			unsigned = append(unsigned, image)
+		}
+	}
+	if len(unsigned) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("The following images are not signed by a trusted signer: %s", strings.Join(unsigned, ", "))
+}
+
+// validateEphemeralContainers validates the ephemeral containers added to a
+// running Pod, for example by `kubectl debug`. Only the images of the
+// ephemeral containers are verified, the other ones were verified when the
+// Pod was created.
+func (ev *evaluator) validateEphemeralContainers(settings *Settings, pod *corev1.Pod) ([]byte, error) {
+	if pod.Spec == nil {
+		return kubewarden.AcceptRequest()
+	}
+
+	spec := &corev1.PodSpec{EphemeralContainers: pod.Spec.EphemeralContainers}
+	if violation := ev.imageSignatureViolation(settings, pod.Metadata, spec); violation != "" {
+		return kubewarden.RejectRequest(kubewarden.Message(violation), kubewarden.NoCode)
+	}
+
+	return kubewarden.AcceptRequest()
+}
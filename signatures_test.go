@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/oci"
+)
+
+const signedPod = `{
+	"metadata": {"name": "test-pod", "namespace": "shop"},
+	"spec": {
+		"containers": [
+			{"name": "app", "image": "registry.example.com/app:1.0"},
+			{"name": "sidecar", "image": "registry.example.com/proxy:2.0"}
+		],
+		"initContainers": [{"name": "init", "image": "registry.example.com/app:1.0"}],
+		"ephemeralContainers": [{"name": "debug", "image": "docker.io/busybox:latest"}]
+	}
+}`
+
+// trustImages makes the sigstore verification trust only the given images,
+// whatever the type of verification.
+func trustImages(host *fakeHostClient, images ...string) {
+	host.Handle("oci", "v2/verify", func(payload []byte) ([]byte, error) {
+		request := struct {
+			Image string `json:"image"`
+		}{}
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+		trusted := false
+		for _, image := range images {
+			trusted = trusted || image == request.Image
+		}
+		return json.Marshal(oci.VerificationResponse{IsTrusted: trusted})
+	})
+}
+
+func TestPodImages(t *testing.T) {
+	pod := corev1.Pod{}
+	if err := json.Unmarshal([]byte(signedPod), &pod); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	images := podImages(pod.Spec)
+	expected := []string{"registry.example.com/app:1.0", "registry.example.com/proxy:2.0", "docker.io/busybox:latest"}
+	if len(images) != len(expected) {
+		t.Fatalf("Got %v instead of %v", images, expected)
+	}
+	for i := range expected {
+		if images[i] != expected[i] {
+			t.Errorf("Got %v instead of %v", images, expected)
+		}
+	}
+
+	if images = podImages(nil); len(images) != 0 {
+		t.Errorf("Unexpected images for a Pod without spec: %v", images)
+	}
+}
+
+func TestImageSignaturesAllSigned(t *testing.T) {
+	host := newFakeHostClient()
+	trustImages(host, "registry.example.com/app:1.0", "registry.example.com/proxy:2.0", "docker.io/busybox:latest")
+	settings := &Settings{
+		DeniedNames:     []DenyEntry{},
+		ImageSignatures: &ImageSignatureSettings{PubKeys: []string{"pem-key"}},
+	}
+
+	response := newRequest(t, signedPod).Settings(settings).Validate(newEvaluatorWithHost(host))
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+
+	calls := host.Calls("oci", "v2/verify")
+	if len(calls) != 3 {
+		t.Fatalf("Expected one verification per image, got %d", len(calls))
+	}
+	request := struct {
+		Type    string   `json:"type"`
+		PubKeys []string `json:"pub_keys"`
+	}{}
+	if err := json.Unmarshal(calls[0].Payload, &request); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if request.Type != "SigstorePubKeyVerify" || len(request.PubKeys) != 1 || request.PubKeys[0] != "pem-key" {
+		t.Errorf("Unexpected verification request: %s", calls[0].Payload)
+	}
+}
+
+func TestImageSignaturesUnsignedImagesAreAggregated(t *testing.T) {
+	host := newFakeHostClient()
+	trustImages(host, "registry.example.com/app:1.0")
+	settings := &Settings{
+		DeniedNames: denyEntries("test-pod"),
+		ImageSignatures: &ImageSignatureSettings{
+			Keyless: []oci.KeylessInfo{{Issuer: "https://token.actions.githubusercontent.com", Subject: "ci@example.com"}},
+		},
+	}
+
+	response := newRequest(t, signedPod).Settings(settings).Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected acceptance")
+	}
+	expected := "The 'test-pod' name is on the deny list; " +
+		"The following images are not signed by a trusted signer: registry.example.com/proxy:2.0, docker.io/busybox:latest"
+	if *response.Message != expected {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expected)
+	}
+}
+
+func TestImageSignaturesRequireAllSigners(t *testing.T) {
+	host := newFakeHostClient()
+	host.Handle("oci", "v2/verify", func(payload []byte) ([]byte, error) {
+		request := struct {
+			Type string `json:"type"`
+		}{}
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+		return json.Marshal(oci.VerificationResponse{IsTrusted: request.Type == "SigstorePubKeyVerify"})
+	})
+	settings := &Settings{
+		DeniedNames: []DenyEntry{},
+		ImageSignatures: &ImageSignatureSettings{
+			PubKeys: []string{"pem-key"},
+			Keyless: []oci.KeylessInfo{{Issuer: "https://accounts.example.com", Subject: "ci@example.com"}},
+		},
+	}
+
+	response := newRequest(t, signedPod).Settings(settings).Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Errorf("Expected images missing the keyless signature to be rejected")
+	}
+}
+
+func TestImageSignaturesVerificationFailure(t *testing.T) {
+	host := newFakeHostClient()
+	host.Fail("oci", "v2/verify", errors.New("registry unreachable"))
+	settings := &Settings{
+		DeniedNames:     []DenyEntry{},
+		ImageSignatures: &ImageSignatureSettings{PubKeys: []string{"pem-key"}},
+	}
+
+	response := newRequest(t, signedPod).Settings(settings).Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected acceptance")
+	}
+	expected := "The following images are not signed by a trusted signer: " +
+		"registry.example.com/app:1.0, registry.example.com/proxy:2.0, docker.io/busybox:latest"
+	if *response.Message != expected {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expected)
+	}
+}
+
+func TestImageSignaturesOfEphemeralContainers(t *testing.T) {
+	oldPod := corev1.Pod{}
+	if err := json.Unmarshal([]byte(signedPod), &oldPod); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	oldPod.Spec.EphemeralContainers = nil
+	settings := &Settings{
+		DeniedNames:     denyEntries("test-pod"),
+		ImageSignatures: &ImageSignatureSettings{PubKeys: []string{"pem-key"}},
+	}
+
+	cases := []struct {
+		trusted  []string
+		accepted bool
+	}{
+		{[]string{"registry.example.com/app:1.0", "registry.example.com/proxy:2.0"}, false},
+		{[]string{"docker.io/busybox:latest"}, true},
+	}
+
+	for _, tc := range cases {
+		host := newFakeHostClient()
+		trustImages(host, tc.trusted...)
+
+		// `kubectl debug` adds the ephemeral containers through the
+		// ephemeralcontainers subresource of the Pod
+		response := updateRequest(t, &oldPod, signedPod).SubResource(subResourceEphemeralContainers).
+			Settings(settings).Validate(newEvaluatorWithHost(host))
+		if response.Accepted != tc.accepted {
+			t.Fatalf("trusting %v: got accepted=%v instead of %v, message: %v",
+				tc.trusted, response.Accepted, tc.accepted, response.Message)
+		}
+		expected := "The following images are not signed by a trusted signer: docker.io/busybox:latest"
+		if !tc.accepted && *response.Message != expected {
+			t.Errorf("Got '%s' instead of '%s'", *response.Message, expected)
+		}
+		// the images of the other containers were verified on creation
+		if calls := host.Calls("oci", "v2/verify"); len(calls) != 1 {
+			t.Errorf("Expected only the ephemeral container to be verified, got %d verifications", len(calls))
+		}
+	}
+}
+
+func TestImageSignatureSettings(t *testing.T) {
+	cases := []struct {
+		name       string
+		signatures ImageSignatureSettings
+		expected   string
+	}{
+		{
+			name:       "no signers",
+			signatures: ImageSignatureSettings{},
+			expected:   "image_signatures: at least one of pub_keys or keyless must be provided",
+		},
+		{
+			name:       "keyless without subject",
+			signatures: ImageSignatureSettings{Keyless: []oci.KeylessInfo{{Issuer: "https://accounts.example.com"}}},
+			expected:   "image_signatures.keyless[0]: issuer and subject must be provided",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := Settings{DeniedNames: []DenyEntry{}, ImageSignatures: &tc.signatures}
+			valid, err := settings.Valid()
+			if valid || err == nil || err.Error() != tc.expected {
+				t.Errorf("Expected error '%s', got valid: %v, error: %v", tc.expected, valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	onelog "github.com/francoispqt/onelog"
+	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	kubewarden "github.com/kubewarden/policy-sdk-go"
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/crypto"
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+)
+
+const (
+	secretTypeTLS      = "kubernetes.io/tls"
+	tlsCertificateKey  = "tls.crt"
+	pemCertificateType = "CERTIFICATE"
+)
+
+// TLSSecretSettings enables the validation of the certificates stored inside
+// of `kubernetes.io/tls` Secrets. The certificates are verified by the
+// Kubewarden host.
+//
+// The content of the Secrets is never logged.
+type TLSSecretSettings struct {
+	// CABundles are PEM encoded bundles of CA certificates. A certificate is
+	// trusted when it is signed by one of the bundles. The CA certificates
+	// trusted by the host are used when left empty.
+	CABundles []string `json:"ca_bundles,omitempty"`
+	// MinValidity is how long the certificate must stay valid, for example
+	// "720h". The certificate must only be valid at the time of the request
+	// when left empty.
+	MinValidity string `json:"min_validity,omitempty"`
+}
+
+// Validate ensures the bundles hold certificates and the minimum validity is
+// a positive duration.
+func (t *TLSSecretSettings) Validate() error {
+	for i, bundle := range t.CABundles {
+		if certificates := pemCertificates([]byte(bundle)); len(certificates) == 0 {
+			return fmt.Errorf("tls_secrets.ca_bundles[%d]: no PEM encoded certificate found", i)
+		}
+	}
+
+	if t.MinValidity != "" {
+		minValidity, err := time.ParseDuration(t.MinValidity)
+		if err != nil {
+			return fmt.Errorf("tls_secrets: invalid 'min_validity': %w", err)
+		}
+		if minValidity <= 0 {
+			return errors.New("tls_secrets: 'min_validity' must be positive")
+		}
+	}
+
+	return nil
+}
+
+// notAfter returns the time, in RFC 3339 format, at which the certificates
+// must still be valid.
+func (t *TLSSecretSettings) notAfter(now time.Time) string {
+	minValidity, err := time.ParseDuration(t.MinValidity)
+	if err != nil {
+		minValidity = 0
+	}
+
+	return now.Add(minValidity).UTC().Format(time.RFC3339)
+}
+
+// pemCertificates returns the PEM encoded certificates found inside of the
+// given data, in the same order.
+func pemCertificates(data []byte) []crypto.Certificate {
+	certificates := []crypto.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certificates
+		}
+		if block.Type != pemCertificateType {
+			continue
+		}
+		certificates = append(certificates, crypto.Certificate{
+			Encoding: crypto.Pem,
+			Data:     []rune(string(pem.EncodeToMemory(block))),
+		})
+	}
+}
+
+// verifyCertificate checks the certificate chain found inside of `tls.crt`
+// against the CA bundles. The first certificate is the one being verified,
+// the other ones are intermediate certificates.
+func (ev *evaluator) verifyCertificate(settings *TLSSecretSettings, chain []crypto.Certificate, now time.Time) error {
+	bundles := [][]crypto.Certificate{nil}
+	if len(settings.CABundles) > 0 {
+		bundles = [][]crypto.Certificate{}
+		for _, bundle := range settings.CABundles {
+			bundles = append(bundles, pemCertificates([]byte(bundle)))
+		}
+	}
+
+	reasons := []string{}
+	for _, bundle := range bundles {
+		certChain := append(append([]crypto.Certificate{}, chain[1:]...), bundle...)
+		response, err := crypto.VerifyCert(ev.capabilitiesHost(), chain[0], certChain, settings.notAfter(now))
+		if err != nil {
+			return fmt.Errorf("cannot verify the certificate: %w", err)
+		}
+		if response.Trusted {
+			return nil
+		}
+		reasons = append(reasons, response.Reason)
+	}
+
+	return errors.New(reasons[0])
+}
+
+// validateTLSSecret validates the Secrets, which are subject only to the
+// check of the certificate of the `kubernetes.io/tls` ones.
+func (ev *evaluator) validateTLSSecret(
+	settings *Settings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+	now time.Time,
+) ([]byte, error) {
+	if settings.TLSSecrets == nil || request.Operation == operationDelete {
+		return kubewarden.AcceptRequest()
+	}
+
+	secret := corev1.Secret{}
+	if err := json.Unmarshal(request.Object, &secret); err != nil {
+		return kubewarden.RejectRequest(
+			kubewarden.Message(fmt.Sprintf("Cannot decode Secret object: %s", err.Error())),
+			kubewarden.Code(httpBadRequestStatusCode))
+	}
+
+	if violation := ev.tlsSecretViolation(settings, request, &secret, now); violation != "" {
+		return kubewarden.RejectRequest(kubewarden.Message(violation), kubewarden.NoCode)
+	}
+
+	return kubewarden.AcceptRequest()
+}
+
+// tlsSecretViolation validates the certificate of `kubernetes.io/tls`
+// Secrets and returns the violation message, or an empty string when the
+// certificate is trusted.
+func (ev *evaluator) tlsSecretViolation(
+	settings *Settings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+	secret *corev1.Secret,
+	now time.Time,
+) string {
+	if secret.Type != secretTypeTLS {
+		return ""
+	}
+
+	certificate := []byte(secret.Data[tlsCertificateKey])
+	if value, found := secret.StringData[tlsCertificateKey]; found {
+		certificate = []byte(value)
+	}
+	chain := pemCertificates(certificate)
+	if len(chain) == 0 {
+		return fmt.Sprintf("The '%s' TLS Secret does not hold a PEM encoded certificate inside of '%s'",
+			request.Name, tlsCertificateKey)
+	}
+
+	if err := ev.verifyCertificate(settings.TLSSecrets, chain, now); err != nil {
+		// never log the certificate, only the outcome of the verification
+		ev.logger.InfoWithFields("rejecting TLS Secret with untrusted certificate", func(e onelog.Entry) {
+			e.String("name", request.Name)
+			e.String("namespace", request.Namespace)
+			e.String("error", err.Error())
+		})
+		return fmt.Sprintf("The certificate of the '%s' TLS Secret is not trusted: %s", request.Name, err.Error())
+	}
+
+	return ""
+}
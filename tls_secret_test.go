@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/policy-sdk-go/pkg/capabilities/crypto"
+)
+
+// testCertificatePEM generates a self-signed PEM encoded certificate.
+func testCertificatePEM(t *testing.T, commonName string) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// trustCertificates makes the certificate verification trust the requests
+// whose chain includes the given CA certificate and whose certificate must be
+// valid until the given time at most.
+func trustCertificates(host *fakeHostClient, caPEM string, validUntil time.Time) {
+	host.Handle("crypto", "v1/is_certificate_trusted", func(payload []byte) ([]byte, error) {
+		request := struct {
+			CertChain []struct {
+				Data []rune `json:"data"`
+			} `json:"cert_chain"`
+			NotAfter string `json:"not_after"`
+		}{}
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return nil, err
+		}
+
+		notAfter, err := time.Parse(time.RFC3339, request.NotAfter)
+		if err != nil {
+			return nil, err
+		}
+		if notAfter.After(validUntil) {
+			return json.Marshal(crypto.CertificateVerificationResponse{Reason: "Certificate expires before " + request.NotAfter})
+		}
+		for _, certificate := range request.CertChain {
+			if string(certificate.Data) == caPEM {
+				return json.Marshal(crypto.CertificateVerificationResponse{Trusted: true})
+			}
+		}
+
+		return json.Marshal(crypto.CertificateVerificationResponse{Reason: "Certificate is not trusted by the provided cert chain"})
+	})
+}
+
+func tlsSecret(secretType, certificate string) string {
+	secret := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]string{"name": "shop-tls", "namespace": "shop"},
+		"type":       secretType,
+		"data": map[string]string{
+			"tls.crt": base64.StdEncoding.EncodeToString([]byte(certificate)),
+			"tls.key": base64.StdEncoding.EncodeToString([]byte("super-secret-private-key")),
+		},
+	}
+	raw, _ := json.Marshal(secret)
+
+	return string(raw)
+}
+
+func TestTLSSecretTrusted(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ca := testCertificatePEM(t, "Example CA")
+	leaf := testCertificatePEM(t, "shop.example.com")
+	intermediate := testCertificatePEM(t, "Example Intermediate")
+
+	host := newFakeHostClient()
+	trustCertificates(host, ca, now.Add(90*24*time.Hour))
+	settings := &Settings{
+		DeniedNames: []DenyEntry{},
+		TLSSecrets:  &TLSSecretSettings{CABundles: []string{ca}, MinValidity: "720h"},
+	}
+
+	ev := newEvaluatorWithHost(host)
+	ev.clock = fixedClock(now)
+	response := newRequest(t, tlsSecret(secretTypeTLS, leaf+intermediate)).Settings(settings).Validate(ev)
+	if !response.Accepted {
+		t.Fatalf("Unexpected rejection: %s", *response.Message)
+	}
+
+	calls := host.Calls("crypto", "v1/is_certificate_trusted")
+	if len(calls) != 1 {
+		t.Fatalf("Expected a single verification, got %d", len(calls))
+	}
+	request := struct {
+		Cert struct {
+			Data []rune `json:"data"`
+		} `json:"cert"`
+		CertChain []struct {
+			Data []rune `json:"data"`
+		} `json:"cert_chain"`
+		NotAfter string `json:"not_after"`
+	}{}
+	if err := json.Unmarshal(calls[0].Payload, &request); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if string(request.Cert.Data) != leaf {
+		t.Errorf("The first certificate of tls.crt should be verified")
+	}
+	if len(request.CertChain) != 2 || string(request.CertChain[0].Data) != intermediate ||
+		string(request.CertChain[1].Data) != ca {
+		t.Errorf("The chain should hold the intermediate and the CA certificates")
+	}
+	if request.NotAfter != "2026-03-31T12:00:00Z" {
+		t.Errorf("Unexpected not_after %s", request.NotAfter)
+	}
+}
+
+func TestTLSSecretRejected(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ca := testCertificatePEM(t, "Example CA")
+	otherCA := testCertificatePEM(t, "Other CA")
+	leaf := testCertificatePEM(t, "shop.example.com")
+
+	cases := []struct {
+		name     string
+		settings TLSSecretSettings
+		expected string
+	}{
+		{
+			name:     "untrusted",
+			settings: TLSSecretSettings{CABundles: []string{otherCA}},
+			expected: "The certificate of the 'shop-tls' TLS Secret is not trusted: " +
+				"Certificate is not trusted by the provided cert chain",
+		},
+		{
+			name:     "expiring soon",
+			settings: TLSSecretSettings{CABundles: []string{ca}, MinValidity: "2160h"},
+			expected: "The certificate of the 'shop-tls' TLS Secret is not trusted: " +
+				"Certificate expires before 2026-05-30T12:00:00Z",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := newFakeHostClient()
+			trustCertificates(host, ca, now.Add(30*24*time.Hour))
+			settings := &Settings{DeniedNames: []DenyEntry{}, TLSSecrets: &tc.settings}
+
+			ev := newEvaluatorWithHost(host)
+			ev.clock = fixedClock(now)
+			logs := newLogSink(t)
+			ev.logger = logs.Logger()
+
+			response := newRequest(t, tlsSecret(secretTypeTLS, leaf)).Settings(settings).Validate(ev)
+			if response.Accepted {
+				t.Fatalf("Unexpected acceptance")
+			}
+			if *response.Message != tc.expected {
+				t.Errorf("Got '%s' instead of '%s'", *response.Message, tc.expected)
+			}
+
+			entry := logs.Find("info", "rejecting TLS Secret with untrusted certificate")
+			if entry.String("name") != "shop-tls" {
+				t.Errorf("Got name '%s' instead of 'shop-tls'", entry.String("name"))
+			}
+			logs.AssertNotLogged(strings.Split(leaf, "\n")[1], "super-secret-private-key")
+		})
+	}
+}
+
+func TestTLSSecretTrustedByAnyBundle(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ca := testCertificatePEM(t, "Example CA")
+	host := newFakeHostClient()
+	trustCertificates(host, ca, now)
+	settings := &Settings{
+		DeniedNames: []DenyEntry{},
+		TLSSecrets:  &TLSSecretSettings{CABundles: []string{testCertificatePEM(t, "Other CA"), ca}},
+	}
+
+	ev := newEvaluatorWithHost(host)
+	ev.clock = fixedClock(now)
+	response := newRequest(t, tlsSecret(secretTypeTLS, testCertificatePEM(t, "shop"))).Settings(settings).Validate(ev)
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+	if calls := host.Calls("crypto", "v1/is_certificate_trusted"); len(calls) != 2 {
+		t.Errorf("Expected one verification per bundle, got %d", len(calls))
+	}
+}
+
+func TestTLSSecretWithoutCertificate(t *testing.T) {
+	host := newFakeHostClient()
+	settings := &Settings{DeniedNames: []DenyEntry{}, TLSSecrets: &TLSSecretSettings{}}
+
+	response := newRequest(t, tlsSecret(secretTypeTLS, "not a certificate")).Settings(settings).Validate(newEvaluatorWithHost(host))
+	if response.Accepted {
+		t.Fatalf("Unexpected acceptance")
+	}
+	expected := "The 'shop-tls' TLS Secret does not hold a PEM encoded certificate inside of 'tls.crt'"
+	if *response.Message != expected {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expected)
+	}
+}
+
+func TestTLSSecretOtherTypesIgnored(t *testing.T) {
+	host := newFakeHostClient()
+	settings := &Settings{DeniedNames: []DenyEntry{}, TLSSecrets: &TLSSecretSettings{}}
+
+	response := newRequest(t, tlsSecret("Opaque", "not a certificate")).Settings(settings).Validate(newEvaluatorWithHost(host))
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+	if calls := host.Calls("crypto", "v1/is_certificate_trusted"); len(calls) != 0 {
+		t.Errorf("Unexpected verifications: %+v", calls)
+	}
+}
+
+func TestTLSSecretSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings TLSSecretSettings
+		expected string
+	}{
+		{
+			name:     "invalid bundle",
+			settings: TLSSecretSettings{CABundles: []string{"not a certificate"}},
+			expected: "tls_secrets.ca_bundles[0]: no PEM encoded certificate found",
+		},
+		{
+			name:     "negative validity",
+			settings: TLSSecretSettings{MinValidity: "-1h"},
+			expected: "tls_secrets: 'min_validity' must be positive",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := Settings{DeniedNames: []DenyEntry{}, TLSSecrets: &tc.settings}
+			valid, err := settings.Valid()
+			if valid || err == nil || err.Error() != tc.expected {
+				t.Errorf("Expected error '%s', got valid: %v, error: %v", tc.expected, valid, err)
+			}
+		})
+	}
+}
+
+func TestTLSSecretOnlyChecksTheCertificate(t *testing.T) {
+	now := time.Date(2026, 12, 24, 0, 0, 0, 0, time.UTC)
+	settings := &Settings{
+		DeniedNames: denyEntries("shop-tls"),
+		FreezeWindows: &FreezeSettings{
+			Absolute: []AbsoluteWindow{{Name: "holidays", Start: now.AddDate(0, 0, -1), End: now.AddDate(0, 0, 1)}},
+		},
+		NameCollision: &NameCollisionSettings{},
+		TLSSecrets:    &TLSSecretSettings{},
+	}
+	host := newFakeHostClient()
+	ev := newEvaluatorWithHost(host)
+	ev.clock = fixedClock(now)
+
+	response := newRequest(t, tlsSecret("Opaque", "not a certificate")).Settings(settings).Validate(ev)
+	if !response.Accepted {
+		t.Errorf("Unexpected rejection: %s", *response.Message)
+	}
+
+	response = newRequest(t, tlsSecret(secretTypeTLS, "not a certificate")).Settings(settings).Validate(ev)
+	expected := "The 'shop-tls' TLS Secret does not hold a PEM encoded certificate inside of 'tls.crt'"
+	if response.Accepted || *response.Message != expected {
+		t.Errorf("Expected rejection with '%s', got %+v", expected, response)
+	}
+	if calls := host.Calls("kubernetes", "list_resources_all"); len(calls) != 0 {
+		t.Errorf("Unexpected lookups: %+v", calls)
+	}
+}
+
+func TestTLSSecretThatCannotBeDecoded(t *testing.T) {
+	settings := &Settings{DeniedNames: []DenyEntry{}, TLSSecrets: &TLSSecretSettings{}}
+
+	response := newRequest(t, `{"kind": "Secret", "metadata": {"name": "shop-tls"}, "type": 42}`).Settings(settings).Validate(newEvaluator())
+	if response.Accepted {
+		t.Fatalf("Unexpected acceptance")
+	}
+	if response.Code == nil || *response.Code != httpBadRequestStatusCode {
+		t.Errorf("Expected code %d, got %v", httpBadRequestStatusCode, response.Code)
+	}
+}
@@ -4,16 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	onelog "github.com/francoispqt/onelog"
 	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
+	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
 	kubewarden "github.com/kubewarden/policy-sdk-go"
 	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
 )
 
-const httpBadRequestStatusCode = 400
+const (
+	httpBadRequestStatusCode          = 400
+	httpInternalServerErrorStatusCode = 500
+	operationUpdate                   = "UPDATE"
+	operationDelete                   = "DELETE"
+)
 
-func validate(payload []byte) ([]byte, error) {
+func (ev *evaluator) validate(payload []byte) ([]byte, error) {
 	// Create a ValidationRequest instance from the incoming payload
 	validationRequest := kubewarden_protocol.ValidationRequest{}
 	err := json.Unmarshal(payload, &validationRequest)
@@ -31,35 +38,329 @@ func validate(payload []byte) ([]byte, error) {
 			kubewarden.Code(httpBadRequestStatusCode))
 	}
 
-	// Access the **raw** JSON that describes the object
-	podJSON := validationRequest.Request.Object
+	now := ev.clock()
+	request := &validationRequest.Request
 
-	// Try to create a Pod instance using the RAW JSON we got from the
-	// ValidationRequest.
-	pod := &corev1.Pod{}
-	if err = json.Unmarshal([]byte(podJSON), pod); err != nil {
+	pod, err := decodePod(request)
+	if err != nil {
 		return kubewarden.RejectRequest(
 			kubewarden.Message(
 				fmt.Sprintf("Cannot decode Pod object: %s", err.Error())),
 			kubewarden.Code(httpBadRequestStatusCode))
 	}
 
-	logger.DebugWithFields("validating pod object", func(e onelog.Entry) {
+	ev.logger.DebugWithFields("validating pod object", func(e onelog.Entry) {
 		e.String("name", pod.Metadata.Name)
 		e.String("namespace", pod.Metadata.Namespace)
 	})
 
-	if settings.IsNameDenied(pod.Metadata.Name) {
-		logger.InfoWithFields("rejecting pod object", func(e onelog.Entry) {
-			e.String("name", pod.Metadata.Name)
-			e.String("denied_names", strings.Join(settings.DeniedNames, ","))
-		})
+	settings, err = ev.applyNamespaceOverride(settings, validationRequest.Settings, requestNamespace(request, pod.Metadata))
+	if err != nil {
+		return kubewarden.RejectRequest(
+			kubewarden.Message(err.Error()),
+			kubewarden.Code(httpBadRequestStatusCode))
+	}
 
+	switch {
+	case request.SubResource == subResourceEphemeralContainers:
+		// ephemeral containers are added to running Pods through their own
+		// subresource, the other rules were evaluated when the Pod was created
+		return ev.validateEphemeralContainers(&settings, pod)
+	case request.Kind.Kind == "Secret":
+		return ev.validateTLSSecret(&settings, request, now)
+	case request.Kind.Kind == "Ingress":
+		return ev.validateIngress(&settings, request)
+	}
+
+	// A valid break-glass token lifts the change freezes and the deny list,
+	// all the other rules still apply
+	bypass := ev.breakGlass(settings.BreakGlass, request, pod.Metadata, now)
+	if !bypass {
+		freeze, err := ev.activeFreeze(&settings, request, now)
+		if err != nil {
+			return kubewarden.RejectRequest(
+				kubewarden.Message(err.Error()),
+				kubewarden.Code(httpBadRequestStatusCode))
+		}
+		if freeze != nil {
+			return kubewarden.RejectRequest(
+				kubewarden.Message(fmt.Sprintf(
+					"%s objects cannot be changed during the '%s' change freeze, which ends at %s",
+					request.Kind.Kind, freeze.Name, freeze.End.Format(time.RFC3339))),
+				kubewarden.NoCode)
+		}
+	}
+
+	// The remaining rules apply only to objects being created or updated
+	if request.Operation == operationDelete {
+		return kubewarden.AcceptRequest()
+	}
+
+	spec, err := podSpec(&validationRequest, pod)
+	if err != nil {
 		return kubewarden.RejectRequest(
 			kubewarden.Message(
-				fmt.Sprintf("The '%s' name is on the deny list", pod.Metadata.Name)),
+				fmt.Sprintf("Cannot decode Pod spec: %s", err.Error())),
+			kubewarden.Code(httpBadRequestStatusCode))
+	}
+
+	violations, err := ev.violations(&settings, request, pod.Metadata, spec, bypass, now)
+	if err != nil {
+		return kubewarden.RejectRequest(
+			kubewarden.Message(err.Error()),
+			kubewarden.Code(httpInternalServerErrorStatusCode))
+	}
+	if len(violations) > 0 {
+		return kubewarden.RejectRequest(
+			kubewarden.Message(strings.Join(violations, "; ")),
 			kubewarden.NoCode)
 	}
 
+	if settings.DigestPinning != nil {
+		return ev.pinDigests(settings.DigestPinning, &validationRequest, spec)
+	}
+
 	return kubewarden.AcceptRequest()
 }
+
+// violations evaluates all the rules that apply to objects being created or
+// updated and returns the messages of the ones that are violated. The deny
+// list is skipped when the object carries a valid break-glass token.
+func (ev *evaluator) violations(
+	settings *Settings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+	metadata *metav1.ObjectMeta,
+	spec *corev1.PodSpec,
+	breakGlass bool,
+	now time.Time,
+) ([]string, error) {
+	violations := []string{}
+
+	if !breakGlass {
+		violation, err := ev.denyListViolation(settings, request, metadata, now)
+		if err != nil {
+			return nil, err
+		}
+		if violation != "" {
+			violations = append(violations, violation)
+		}
+	}
+
+	if violation := ev.nameCollisionViolation(settings, request, metadata); violation != "" {
+		violations = append(violations, violation)
+	}
+
+	if violation := ev.imageSignatureViolation(settings, metadata, spec); violation != "" {
+		violations = append(violations, violation)
+	}
+
+	return violations, nil
+}
+
+// decodePod creates a Pod instance using the RAW JSON of the request.
+func decodePod(request *kubewarden_protocol.KubernetesAdmissionRequest) (*corev1.Pod, error) {
+	// Access the **raw** JSON that describes the object. DELETE requests
+	// carry only the object being removed.
+	podJSON := request.Object
+	if request.Operation == operationDelete {
+		podJSON = request.OldObject
+	}
+
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal([]byte(podJSON), pod); err != nil {
+		return nil, err
+	}
+	if pod.Metadata == nil {
+		pod.Metadata = &metav1.ObjectMeta{}
+	}
+
+	return pod, nil
+}
+
+// requestNamespace returns the namespace of the object being validated.
+func requestNamespace(request *kubewarden_protocol.KubernetesAdmissionRequest, metadata *metav1.ObjectMeta) string {
+	if request.Namespace != "" {
+		return request.Namespace
+	}
+
+	return metadata.Namespace
+}
+
+// denyListViolation checks the name of the object against the deny list and
+// returns the violation message, or an empty string when the name is allowed.
+// An error is returned when the deny list cannot be loaded.
+func (ev *evaluator) denyListViolation(
+	settings *Settings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+	metadata *metav1.ObjectMeta,
+	now time.Time,
+) (string, error) {
+	// Names cannot change once the object exists, enforcing the deny list
+	// on UPDATE would only block label changes and finalizer removals
+	if request.Operation == operationUpdate {
+		return "", nil
+	}
+
+	if settings.DenyListSource != nil {
+		entries, err := ev.loadDenyList(settings.DenyListSource)
+		switch {
+		case err == nil:
+			settings.DeniedNames = append(settings.DeniedNames, entries...)
+		case settings.DenyListSource.FailOpen():
+			ev.logger.WarnWithFields("cannot load deny list, ignoring it", func(e onelog.Entry) {
+				e.String("source", settings.DenyListSource.String())
+				e.String("error", err.Error())
+			})
+		default:
+			return "", fmt.Errorf("Cannot load the deny list from ConfigMap %s: %w", //nolint:staticcheck // shown to the user as is
+				settings.DenyListSource.String(), err)
+		}
+	}
+
+	namespace := requestNamespace(request, metadata)
+
+	for _, expired := range settings.ExpiredDenyEntries(now) {
+		ev.logger.InfoWithFields("ignoring expired deny entry", func(e onelog.Entry) {
+			e.String("name", expired.Name)
+			e.String("expires_at", expired.ExpiresAt.Format(time.RFC3339))
+		})
+	}
+
+	if entry := settings.FindDenyEntry(metadata.Name, now); entry != nil {
+		if !ev.namespaceInScope(settings, namespace) {
+			ev.logger.DebugWithFields("namespace is not subject to the deny list", func(e onelog.Entry) {
+				e.String("name", metadata.Name)
+				e.String("namespace", namespace)
+			})
+			return "", nil
+		}
+
+		if !settings.IsEnforced(namespace, metadata.Name) {
+			ev.logger.InfoWithFields("not enforcing deny list violation because of the rollout percentage",
+				func(e onelog.Entry) {
+					e.String("name", metadata.Name)
+					e.String("namespace", namespace)
+					e.Int("rollout_percentage", settings.RolloutPercentageFor(namespace))
+				})
+			return "", nil
+		}
+
+		if ev.isExempt(settings, &request.UserInfo) {
+			ev.logger.InfoWithFields("allowing denied name to user exempt by access review", func(e onelog.Entry) {
+				e.String("name", metadata.Name)
+				e.String("namespace", namespace)
+				e.String("user", request.UserInfo.Username)
+			})
+			return "", nil
+		}
+
+		ev.logger.InfoWithFields("rejecting pod object", func(e onelog.Entry) {
+			e.String("name", metadata.Name)
+			e.String("denied_names", strings.Join(settings.DeniedNamesList(), ","))
+			e.String("reason", entry.Reason)
+			e.String("owner", entry.Owner)
+			e.String("ticket", entry.Ticket)
+		})
+
+		return denyMessage(metadata.Name, entry), nil
+	}
+
+	return "", nil
+}
+
+// breakGlass verifies the break-glass token carried by the object, if any,
+// and returns true when a valid token lets the request bypass the deny rules.
+// Invalid tokens are only logged: the object is then evaluated like any other
+// one, because it would otherwise be stuck once its token has expired.
+func (ev *evaluator) breakGlass(
+	breakGlass *BreakGlassSettings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+	metadata *metav1.ObjectMeta,
+	now time.Time,
+) bool {
+	// the token of an object being deleted was meant for its creation
+	if breakGlass == nil || request.Operation == operationDelete {
+		return false
+	}
+	token, found := metadata.Annotations[breakGlass.AnnotationName()]
+	if !found {
+		return false
+	}
+
+	// the namespace of the object is often left out on CREATE
+	namespace := requestNamespace(request, metadata)
+	claims, err := breakGlass.Verify(token, namespace, metadata.Name, now)
+	if err != nil {
+		ev.logger.WarnWithFields("ignoring invalid break-glass token", func(e onelog.Entry) {
+			e.String("name", metadata.Name)
+			e.String("namespace", namespace)
+			e.String("operation", request.Operation)
+			e.String("user", request.UserInfo.Username)
+			e.String("groups", strings.Join(request.UserInfo.Groups, ","))
+			e.String("annotation", breakGlass.AnnotationName())
+			e.String("error", err.Error())
+		})
+
+		return false
+	}
+
+	ev.logger.WarnWithFields("bypassing deny rules with break-glass token", func(e onelog.Entry) {
+		e.String("name", metadata.Name)
+		e.String("namespace", namespace)
+		e.String("operation", request.Operation)
+		e.String("user", request.UserInfo.Username)
+		e.String("groups", strings.Join(request.UserInfo.Groups, ","))
+		e.String("key_id", claims.KeyID)
+		e.String("reason", claims.Reason)
+		e.String("expires_at", claims.ExpiresAt.Format(time.RFC3339))
+	})
+
+	return true
+}
+
+// activeFreeze returns the change-freeze window that blocks the request, or
+// nil when the request can go through.
+func (ev *evaluator) activeFreeze(
+	settings *Settings,
+	request *kubewarden_protocol.KubernetesAdmissionRequest,
+	now time.Time,
+) (*ActiveFreeze, error) {
+	if settings.FreezeWindows == nil || !settings.FreezeWindows.AppliesTo(request.Kind.Kind) {
+		return nil, nil //nolint:nilnil // the request is not subject to any freeze
+	}
+
+	freeze, err := settings.FreezeWindows.ActiveWindow(now)
+	if err != nil || freeze == nil {
+		return nil, err
+	}
+
+	if settings.FreezeWindows.IsExempt(&request.UserInfo) || ev.isExempt(settings, &request.UserInfo) {
+		ev.logger.InfoWithFields("allowing change of exempt user during change freeze", func(e onelog.Entry) {
+			e.String("window", freeze.Name)
+			e.String("user", request.UserInfo.Username)
+			e.String("operation", request.Operation)
+		})
+		return nil, nil //nolint:nilnil // exempt users are not subject to the freeze
+	}
+
+	ev.logger.InfoWithFields("rejecting change during change freeze", func(e onelog.Entry) {
+		e.String("window", freeze.Name)
+		e.String("kind", request.Kind.Kind)
+		e.String("name", request.Name)
+		e.String("namespace", request.Namespace)
+		e.String("operation", request.Operation)
+		e.String("user", request.UserInfo.Username)
+	})
+
+	return freeze, nil
+}
+
+// denyMessage builds the rejection message for a name found on the deny list.
+func denyMessage(name string, entry *DenyEntry) string {
+	msg := fmt.Sprintf("The '%s' name is on the deny list", name)
+	if details := entry.Describe(); details != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, details)
+	}
+
+	return msg
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	corev1 "github.com/kubewarden/k8s-objects/api/core/v1"
 	metav1 "github.com/kubewarden/k8s-objects/apimachinery/pkg/apis/meta/v1"
@@ -41,7 +42,7 @@ func TestEmptySettingsLeadsToApproval(t *testing.T) {
 
 func TestApproval(t *testing.T) {
 	settings := Settings{
-		DeniedNames: []string{"foo", "bar"},
+		DeniedNames: denyEntries("foo", "bar"),
 	}
 	pod := corev1.Pod{
 		Metadata: &metav1.ObjectMeta{
@@ -72,7 +73,7 @@ func TestApproval(t *testing.T) {
 
 func TestApproveFixture(t *testing.T) {
 	settings := Settings{
-		DeniedNames: []string{},
+		DeniedNames: []DenyEntry{},
 	}
 
 	payload, err := kubewarden_testing.BuildValidationRequestFromFixture(
@@ -99,7 +100,7 @@ func TestApproveFixture(t *testing.T) {
 
 func TestRejectionBecauseNameIsDenied(t *testing.T) {
 	settings := Settings{
-		DeniedNames: []string{"foo", "test-pod"},
+		DeniedNames: denyEntries("foo", "test-pod"),
 	}
 
 	pod := corev1.Pod{
@@ -136,3 +137,115 @@ func TestRejectionBecauseNameIsDenied(t *testing.T) {
 		t.Errorf("Got '%s' instead of '%s'", *response.Message, expectedMessage)
 	}
 }
+
+func TestRejectionMessageIncludesReasonAndOwner(t *testing.T) {
+	settings := Settings{
+		DeniedNames: []DenyEntry{
+			{Name: "test-pod", Reason: "reserved for the platform team", Owner: "team-x", Ticket: "SEC-123"},
+		},
+	}
+
+	pod := corev1.Pod{
+		Metadata: &metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+		},
+	}
+
+	payload, err := kubewarden_testing.BuildValidationRequest(&pod, &settings)
+	if err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+
+	responsePayload, err := validate(payload)
+	if err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+
+	var response kubewarden_protocol.ValidationResponse
+	if err = json.Unmarshal(responsePayload, &response); err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+
+	if response.Accepted != false {
+		t.Error("Unexpected approval")
+	}
+
+	expectedMessage := "The 'test-pod' name is on the deny list (reserved for the platform team, owner: team-x)"
+	if response.Message == nil {
+		t.Fatalf("expected response to have a message")
+	}
+	if *response.Message != expectedMessage {
+		t.Errorf("Got '%s' instead of '%s'", *response.Message, expectedMessage)
+	}
+}
+
+// fixedClock returns a clock that always reports the given time.
+func fixedClock(now time.Time) func() time.Time {
+	return func() time.Time {
+		return now
+	}
+}
+
+func TestExpiredDenyEntryIsIgnored(t *testing.T) {
+	expiresAt := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	settings := Settings{
+		DeniedNames: []DenyEntry{
+			{Name: "test-pod", ExpiresAt: &expiresAt},
+		},
+	}
+
+	cases := []struct {
+		now      time.Time
+		accepted bool
+	}{
+		{expiresAt.Add(-time.Minute), false},
+		{expiresAt.Add(time.Minute), true},
+	}
+
+	for _, tc := range cases {
+		ev := newEvaluator()
+		ev.clock = fixedClock(tc.now)
+		response := newRequest(t, testPod("test-pod", "default")).Settings(&settings).Validate(ev)
+		if response.Accepted != tc.accepted {
+			t.Errorf("at %s: got accepted=%v instead of %v", tc.now, response.Accepted, tc.accepted)
+		}
+	}
+}
+
+func TestRejectionIsLogged(t *testing.T) {
+	settings := Settings{
+		DeniedNames: []DenyEntry{
+			{Name: "foo"},
+			{Name: "test-pod", Reason: "reserved for the platform team", Owner: "team-x", Ticket: "SEC-123"},
+		},
+	}
+	pod := corev1.Pod{
+		Metadata: &metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+		},
+	}
+
+	ev := newEvaluator()
+	logs := newLogSink(t)
+	ev.logger = logs.Logger()
+
+	response := newRequest(t, &pod).Settings(&settings).Validate(ev)
+	if response.Accepted {
+		t.Fatal("Unexpected approval")
+	}
+
+	entry := logs.Find("info", "rejecting pod object")
+	expected := map[string]string{
+		"name":         "test-pod",
+		"denied_names": "foo,test-pod",
+		"owner":        "team-x",
+		"ticket":       "SEC-123",
+	}
+	for key, value := range expected {
+		if entry.String(key) != value {
+			t.Errorf("Got %s '%s' instead of '%s'", key, entry.String(key), value)
+		}
+	}
+}
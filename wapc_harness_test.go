@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	kubewarden_protocol "github.com/kubewarden/policy-sdk-go/protocol"
+	wapc "github.com/wapc/wapc-guest-tinygo"
+)
+
+// wapcHarness calls the policy the way the Kubewarden hosts, kwctl and the
+// policy server, do: through the waPC functions registered by `main()`,
+// looked up by name.
+type wapcHarness struct {
+	functions wapc.Functions
+}
+
+func newWapcHarness() *wapcHarness {
+	functions := wapc.Functions{}
+	for name, fn := range wapcFunctions() {
+		functions[name] = fn
+	}
+
+	return &wapcHarness{functions: functions}
+}
+
+// at returns a copy of the harness whose `validate` and `validate_settings`
+// functions evaluate the requests at the given time, instead of the current
+// one.
+func (h *wapcHarness) at(now time.Time) *wapcHarness {
+	functions := wapc.Functions{}
+	for name, fn := range h.functions {
+		functions[name] = fn
+	}
+	functions["validate"] = func(payload []byte) ([]byte, error) {
+		ev := newEvaluator()
+		ev.clock = fixedClock(now)
+		return ev.validate(payload)
+	}
+	functions["validate_settings"] = func(payload []byte) ([]byte, error) {
+		ev := newEvaluator()
+		ev.clock = fixedClock(now)
+		return ev.validateSettings(payload)
+	}
+
+	return &wapcHarness{functions: functions}
+}
+
+// call invokes the given waPC function. Errors are reported like the guest
+// does when running inside of the host.
+func (h *wapcHarness) call(operation string, payload []byte) ([]byte, error) {
+	fn, found := h.functions[operation]
+	if !found {
+		return nil, fmt.Errorf(`Could not find function "%s"`, operation) //nolint:staticcheck // same message of the waPC guest
+	}
+
+	return fn(payload)
+}
+
+// evaluate mirrors what the host does when evaluating a request: validate the
+// settings, refusing to go further when they are not valid, and finally
+// validate the request. The host checks the protocol version first, but the
+// SDK registers `protocol_version` only when building with TinyGo: the
+// harness cannot call it, it is checked by the kwctl tests of TestE2E
+// against the WebAssembly module.
+func (h *wapcHarness) evaluate(validationRequest *kubewarden_protocol.ValidationRequest) (kubewarden_protocol.ValidationResponse, error) {
+	response := kubewarden_protocol.ValidationResponse{}
+
+	rawSettingsResponse, err := h.call("validate_settings", validationRequest.Settings)
+	if err != nil {
+		return response, fmt.Errorf("validate_settings: %w", err)
+	}
+	settingsResponse := kubewarden_protocol.SettingsValidationResponse{}
+	if err = json.Unmarshal(rawSettingsResponse, &settingsResponse); err != nil {
+		return response, fmt.Errorf("validate_settings: invalid response: %w", err)
+	}
+	if !settingsResponse.Valid {
+		message := ""
+		if settingsResponse.Message != nil {
+			message = *settingsResponse.Message
+		}
+		return response, fmt.Errorf("validate_settings: settings are not valid: %s", message)
+	}
+
+	payload, err := json.Marshal(validationRequest)
+	if err != nil {
+		return response, err
+	}
+	rawResponse, err := h.call("validate", payload)
+	if err != nil {
+		return response, fmt.Errorf("validate: %w", err)
+	}
+	if err = json.Unmarshal(rawResponse, &response); err != nil {
+		return response, fmt.Errorf("validate: invalid response: %w", err)
+	}
+
+	return response, nil
+}
+
+func TestWapcRegistration(t *testing.T) {
+	harness := newWapcHarness()
+
+	for _, name := range []string{"validate", "validate_settings"} {
+		if _, found := harness.functions[name]; !found {
+			t.Errorf("Function %q is not registered", name)
+		}
+	}
+
+	if _, err := harness.call("validate_request", nil); err == nil ||
+		err.Error() != `Could not find function "validate_request"` {
+		t.Errorf("Unexpected error for an unknown function: %v", err)
+	}
+}
+
+func TestWapcHarnessEvaluation(t *testing.T) {
+	pod := `{"metadata": {"name": "test-pod", "namespace": "default"}}`
+
+	cases := []struct {
+		name     string
+		settings Settings
+		accepted bool
+		message  string
+	}{
+		{"accepted", Settings{DeniedNames: denyEntries("foo")}, true, ""},
+		{"rejected", Settings{DeniedNames: denyEntries("foo", "test-pod")}, false, "The 'test-pod' name is on the deny list"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			validationRequest := newRequest(t, pod).Settings(tc.settings).Build()
+
+			response, err := newWapcHarness().evaluate(&validationRequest)
+			if err != nil {
+				t.Fatalf("Unexpected error: %+v", err)
+			}
+			if response.Accepted != tc.accepted {
+				t.Errorf("Got accepted=%v instead of %v", response.Accepted, tc.accepted)
+			}
+			if !tc.accepted && *response.Message != tc.message {
+				t.Errorf("Got '%s' instead of '%s'", *response.Message, tc.message)
+			}
+		})
+	}
+}
+
+func TestWapcHarnessRefusesInvalidSettings(t *testing.T) {
+	validationRequest := newRequest(t, `{"metadata": {"name": "test-pod"}}`).
+		Settings(Settings{DeniedNames: []DenyEntry{{Reason: "no name"}}}).
+		Build()
+
+	_, err := newWapcHarness().evaluate(&validationRequest)
+	expected := "validate_settings: settings are not valid: Provided settings are not valid: denied_names[0]: name cannot be empty"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error '%s', got: %v", expected, err)
+	}
+}
+
+func TestWapcHarnessMalformedPayload(t *testing.T) {
+	rawResponse, err := newWapcHarness().call("validate", []byte("not json"))
+	if err != nil {
+		t.Fatalf("Malformed payloads should be rejected, not fail: %+v", err)
+	}
+
+	response := kubewarden_protocol.ValidationResponse{}
+	if err = json.Unmarshal(rawResponse, &response); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if response.Accepted || response.Code == nil || *response.Code != httpBadRequestStatusCode {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+}